@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// relationshipTarget authenticates the caller and resolves the {userID} path
+// value shared by the block and mute endpoints. It writes the error response
+// itself and returns ok=false when the request can't go on.
+func (ac *apiConfig) relationshipTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID format")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetId == userId {
+		respondWithError(w, 400, "You can't do that to yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	_, err = ac.Queries.GetUserById(r.Context(), targetId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userId, targetId, true
+}
+
+// blockUserHandler hides both users' chirps from each other and stops the
+// blocked user from mentioning the blocker.
+func (ac *apiConfig) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, targetId, ok := ac.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.CreateBlock(r.Context(), database.CreateBlockParams{
+		BlockerID: userId,
+		BlockedID: targetId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (ac *apiConfig) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, targetId, ok := ac.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.DeleteBlock(r.Context(), database.DeleteBlockParams{
+		BlockerID: userId,
+		BlockedID: targetId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// muteUserHandler hides the muted user's chirps from the caller only.
+func (ac *apiConfig) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, targetId, ok := ac.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.CreateMute(r.Context(), database.CreateMuteParams{
+		MuterID: userId,
+		MutedID: targetId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (ac *apiConfig) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId, targetId, ok := ac.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.DeleteMute(r.Context(), database.DeleteMuteParams{
+		MuterID: userId,
+		MutedID: targetId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// chirpTarget authenticates the request and loads its {chirpID}, answering
+// 404 for chirps the caller can't see.
+func (ac *apiConfig) chirpTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Chirp, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID format")
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	dbChirp, err := ac.Queries.GetChirpById(r.Context(), chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Chirp Not found!")
+		return uuid.Nil, database.Chirp{}, false
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	visible, err := ac.canSeeChirp(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, dbChirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return uuid.Nil, database.Chirp{}, false
+	}
+	if !visible {
+		respondWithError(w, 404, "Chirp Not found!")
+		return uuid.Nil, database.Chirp{}, false
+	}
+
+	return userId, dbChirp, true
+}
+
+func (ac *apiConfig) bookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userId, dbChirp, ok := ac.chirpTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:  userId,
+		ChirpID: dbChirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// unbookmarkChirpHandler doesn't check visibility, a chirp that became
+// hidden can still be removed from the bookmarks.
+func (ac *apiConfig) unbookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID format")
+		return
+	}
+
+	err = ac.Queries.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userId,
+		ChirpID: chirpId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// getBookmarksHandler lists the caller's bookmarks, most recently bookmarked
+// first. The cursor is on the bookmark's creation time, not the chirp's.
+func (ac *apiConfig) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetBookmarkedChirpsParams{
+		UserID:     userId,
+		MaxResults: limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID.UUID, params.BeforeID.Valid = id, true
+	}
+
+	results, err := ac.Queries.GetBookmarkedChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	nextCursor := ""
+	if len(results) > int(limit) {
+		results = results[:limit]
+		last := results[len(results)-1]
+		nextCursor = encodeCursor(last.BookmarkedAt, last.ID)
+	}
+
+	dbChirps := make([]database.Chirp, 0, len(results))
+	for _, result := range results {
+		dbChirps = append(dbChirps, database.Chirp{
+			ID:               result.ID,
+			CreatedAt:        result.CreatedAt,
+			UpdatedAt:        result.UpdatedAt,
+			Body:             result.Body,
+			UserID:           result.UserID,
+			ModerationStatus: result.ModerationStatus,
+			Visibility:       result.Visibility,
+			PreviewUrl:       result.PreviewUrl,
+			EditedAt:         result.EditedAt,
+		})
+	}
+
+	viewer := uuid.NullUUID{UUID: userId, Valid: true}
+	dbChirps, err = ac.filterChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	chirps, err := ac.renderChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+	})
+}
+
+// pinChirpHandler pins one of the caller's own chirps to the top of their
+// profile, replacing any previous pin.
+func (ac *apiConfig) pinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userId, dbChirp, ok := ac.chirpTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if dbChirp.UserID != userId {
+		respondWithError(w, 403, "You can only pin your own chirps")
+		return
+	}
+
+	err := ac.Queries.PinChirp(r.Context(), database.PinChirpParams{
+		PinnedChirpID: uuid.NullUUID{UUID: dbChirp.ID, Valid: true},
+		ID:            userId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (ac *apiConfig) unpinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid chirp ID format")
+		return
+	}
+
+	unpinned, err := ac.Queries.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		ID:            userId,
+		PinnedChirpID: uuid.NullUUID{UUID: chirpId, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if unpinned == 0 {
+		respondWithError(w, 404, "Chirp is not pinned")
+		return
+	}
+
+	w.WriteHeader(204)
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// Members of a group conversation, its creator included.
+	MAX_CONVERSATION_SIZE = 8
+	MAX_MESSAGE_LENGTH    = 1000
+)
+
+var (
+	errDMBlocked     = errors.New("can't message a user with a block between you")
+	errDMNotAccepted = errors.New("doesn't accept direct messages")
+)
+
+// canMessage checks that sender may start a conversation with recipient.
+// There is no follow graph yet, so the recipient has to opt in through
+// accepts_dms.
+func canMessage(ctx context.Context, q *database.Queries, sender uuid.UUID, recipient database.User) error {
+	blocked, err := q.BlockExists(ctx, database.BlockExistsParams{
+		UserA: sender,
+		UserB: recipient.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return fmt.Errorf("%w: @%s", errDMBlocked, recipient.Handle.String)
+	}
+
+	if !recipient.AcceptsDms {
+		return fmt.Errorf("@%s %w", recipient.Handle.String, errDMNotAccepted)
+	}
+
+	return nil
+}
+
+// renderConversations converts database conversations into their JSON form
+// along with their members.
+func (ac *apiConfig) renderConversations(ctx context.Context, dbConversations []database.GetUserConversationsRow) ([]Conversation, error) {
+	conversations := make([]Conversation, 0, len(dbConversations))
+	if len(dbConversations) == 0 {
+		return conversations, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(dbConversations))
+	byId := make(map[uuid.UUID]int, len(dbConversations))
+	for i, dbConversation := range dbConversations {
+		conversations = append(conversations, Conversation{
+			ID:          dbConversation.ID,
+			CreatedAt:   dbConversation.CreatedAt,
+			UpdatedAt:   dbConversation.UpdatedAt,
+			IsGroup:     dbConversation.IsGroup,
+			Members:     make([]ConversationMember, 0, 2),
+			UnreadCount: int(dbConversation.UnreadCount),
+		})
+		ids = append(ids, dbConversation.ID)
+		byId[dbConversation.ID] = i
+	}
+
+	members, err := ac.Queries.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		conversation := &conversations[byId[member.ConversationID]]
+		conversation.Members = append(conversation.Members, toConversationMember(member))
+	}
+
+	return conversations, nil
+}
+
+func toConversationMember(member database.GetConversationMembersRow) ConversationMember {
+	result := ConversationMember{
+		UserID:      member.UserID,
+		Handle:      member.Handle.String,
+		DisplayName: member.DisplayName.String,
+	}
+	if member.LastReadAt.Valid {
+		result.LastReadAt = &member.LastReadAt.Time
+	}
+
+	return result
+}
+
+// toMessage converts a message, listing as readers the other members whose
+// read receipt is at or past it.
+func toMessage(dbMessage database.Message, members []database.GetConversationMembersRow) Message {
+	message := Message{
+		ID:             dbMessage.ID,
+		CreatedAt:      dbMessage.CreatedAt,
+		ConversationID: dbMessage.ConversationID,
+		SenderID:       dbMessage.SenderID,
+		Body:           dbMessage.Body,
+		ReadBy:         make([]uuid.UUID, 0),
+	}
+
+	for _, member := range members {
+		if member.UserID == dbMessage.SenderID || !member.LastReadAt.Valid {
+			continue
+		}
+		if !member.LastReadAt.Time.Before(dbMessage.CreatedAt) {
+			message.ReadBy = append(message.ReadBy, member.UserID)
+		}
+	}
+
+	return message
+}
+
+// createConversationHandler starts a conversation between the caller and
+// member_ids: a direct one with a single member, a group otherwise. Asking
+// for a direct conversation that already exists returns it.
+func (ac *apiConfig) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData ConversationRequest
+	err = decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	memberIds := make([]uuid.UUID, 0, len(reqData.MemberIds))
+	seen := map[uuid.UUID]bool{userId: true}
+	for _, memberId := range reqData.MemberIds {
+		if !seen[memberId] {
+			seen[memberId] = true
+			memberIds = append(memberIds, memberId)
+		}
+	}
+
+	if len(memberIds) == 0 || len(memberIds) > MAX_CONVERSATION_SIZE-1 {
+		respondWithError(w, 400, fmt.Sprintf("A conversation needs 1 to %d other members", MAX_CONVERSATION_SIZE-1))
+		return
+	}
+
+	for _, memberId := range memberIds {
+		member, err := ac.Queries.GetUserById(r.Context(), memberId)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 404, "User not found")
+			return
+		}
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+
+		err = canMessage(r.Context(), ac.Queries, userId, member)
+		if errors.Is(err, errDMBlocked) || errors.Is(err, errDMNotAccepted) {
+			respondWithError(w, 403, err.Error())
+			return
+		}
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+	}
+
+	isGroup := len(memberIds) > 1
+
+	directKey := sql.NullString{}
+	if !isGroup {
+		directKey = sql.NullString{String: directConversationKey(userId, memberIds[0]), Valid: true}
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	conversation, err := qtx.CreateConversation(r.Context(), database.CreateConversationParams{
+		CreatorID: userId,
+		IsGroup:   isGroup,
+		DirectKey: directKey,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// There already is a conversation between the two, even one created
+		// by a concurrent request, which the insert waited for
+		existing, err := qtx.GetConversationByDirectKey(r.Context(), directKey)
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+		ac.respondWithConversation(w, r, 200, existing)
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	for _, memberId := range append([]uuid.UUID{userId}, memberIds...) {
+		err = qtx.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+			ConversationID: conversation.ID,
+			UserID:         memberId,
+		})
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	ac.respondWithConversation(w, r, 201, conversation)
+}
+
+// directConversationKey identifies the 1:1 conversation between two users,
+// the same whoever starts it.
+func directConversationKey(a, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+
+	return a.String() + ":" + b.String()
+}
+
+func (ac *apiConfig) respondWithConversation(w http.ResponseWriter, r *http.Request, code int, conversation database.Conversation) {
+	conversations, err := ac.renderConversations(r.Context(), []database.GetUserConversationsRow{{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		CreatorID: conversation.CreatorID,
+		IsGroup:   conversation.IsGroup,
+	}})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, code, conversations[0])
+}
+
+// getConversationsHandler lists the caller's conversations, most recently
+// active first.
+func (ac *apiConfig) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetUserConversationsParams{
+		UserID:     userId,
+		MaxResults: limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		updatedAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeUpdatedAt = sql.NullTime{Time: updatedAt, Valid: true}
+		params.BeforeID.UUID, params.BeforeID.Valid = id, true
+	}
+
+	dbConversations, err := ac.Queries.GetUserConversations(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	nextCursor := ""
+	if len(dbConversations) > int(limit) {
+		dbConversations = dbConversations[:limit]
+		last := dbConversations[len(dbConversations)-1]
+		nextCursor = encodeCursor(last.UpdatedAt, last.ID)
+	}
+
+	conversations, err := ac.renderConversations(r.Context(), dbConversations)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, ConversationPage{
+		Conversations: conversations,
+		NextCursor:    nextCursor,
+	})
+}
+
+// conversationTarget authenticates the request and loads its
+// {conversationID} and members. Conversations the caller is not part of
+// don't exist as far as they are concerned.
+func (ac *apiConfig) conversationTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.Conversation, []database.GetConversationMembersRow, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return uuid.Nil, database.Conversation{}, nil, false
+	}
+
+	conversationId, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid conversation ID format")
+		return uuid.Nil, database.Conversation{}, nil, false
+	}
+
+	conversation, err := ac.Queries.GetConversationById(r.Context(), conversationId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Conversation not found")
+		return uuid.Nil, database.Conversation{}, nil, false
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return uuid.Nil, database.Conversation{}, nil, false
+	}
+
+	members, err := ac.Queries.GetConversationMembers(r.Context(), []uuid.UUID{conversationId})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return uuid.Nil, database.Conversation{}, nil, false
+	}
+
+	for _, member := range members {
+		if member.UserID == userId {
+			return userId, conversation, members, true
+		}
+	}
+
+	respondWithError(w, 404, "Conversation not found")
+	return uuid.Nil, database.Conversation{}, nil, false
+}
+
+// sendMessageHandler posts a message to a conversation. Blocks are checked on
+// every message, a block between the sender and any other member stops it.
+func (ac *apiConfig) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, conversation, members, ok := ac.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData MessageRequest
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	body := strings.TrimSpace(reqData.Body)
+	if body == "" || utf8.RuneCountInString(body) > MAX_MESSAGE_LENGTH {
+		respondWithError(w, 400, fmt.Sprintf("Messages must be 1 to %d characters long", MAX_MESSAGE_LENGTH))
+		return
+	}
+
+	for _, member := range members {
+		if member.UserID == userId {
+			continue
+		}
+
+		blocked, err := ac.Queries.BlockExists(r.Context(), database.BlockExistsParams{
+			UserA: userId,
+			UserB: member.UserID,
+		})
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+		if blocked {
+			respondWithError(w, 403, fmt.Sprintf("%s: @%s", errDMBlocked, member.Handle.String))
+			return
+		}
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	message, err := qtx.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: conversation.ID,
+		SenderID:       userId,
+		Body:           body,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = qtx.TouchConversation(r.Context(), conversation.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	// Senders have obviously read everything up to their own message
+	err = qtx.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         message.CreatedAt,
+		ConversationID: conversation.ID,
+		UserID:         userId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	resMessage := toMessage(message, members)
+
+	memberIds := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		memberIds = append(memberIds, member.UserID)
+	}
+
+	ac.emit(r.Context(), EVENT_MESSAGE_SENT, MessageEvent{
+		Message:   resMessage,
+		MemberIds: memberIds,
+	})
+
+	respondWithJSON(w, 201, resMessage)
+}
+
+// getMessagesHandler lists the messages of a conversation, newest first.
+func (ac *apiConfig) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	_, conversation, members, ok := ac.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetMessagesParams{
+		ConversationID: conversation.ID,
+		MaxResults:     limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID.UUID, params.BeforeID.Valid = id, true
+	}
+
+	dbMessages, err := ac.Queries.GetMessages(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	nextCursor := ""
+	if len(dbMessages) > int(limit) {
+		dbMessages = dbMessages[:limit]
+		last := dbMessages[len(dbMessages)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	messages := make([]Message, 0, len(dbMessages))
+	for _, dbMessage := range dbMessages {
+		messages = append(messages, toMessage(dbMessage, members))
+	}
+
+	respondWithJSON(w, 200, MessagePage{
+		Messages:   messages,
+		NextCursor: nextCursor,
+	})
+}
+
+// markConversationReadHandler moves the caller's read receipt up to
+// message_id, or to the latest message without a body. Receipts never move
+// backwards.
+func (ac *apiConfig) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, conversation, _, ok := ac.conversationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	var reqData struct {
+		MessageID *uuid.UUID `json:"message_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	err := decoder.Decode(&reqData)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	var message database.Message
+	if reqData.MessageID != nil {
+		message, err = ac.Queries.GetMessageById(r.Context(), database.GetMessageByIdParams{
+			ID:             *reqData.MessageID,
+			ConversationID: conversation.ID,
+		})
+	} else {
+		message, err = ac.Queries.GetLatestMessage(r.Context(), conversation.ID)
+	}
+	if errors.Is(err, sql.ErrNoRows) && reqData.MessageID != nil {
+		respondWithError(w, 404, "Message not found")
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// Nothing to read yet
+		w.WriteHeader(204)
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = ac.Queries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ReadAt:         message.CreatedAt,
+		ConversationID: conversation.ID,
+		UserID:         userId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
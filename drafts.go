@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	DRAFTS_INTERVAL = time.Second * 15
+	// How far ahead a chirp can be scheduled.
+	MAX_SCHEDULE_AHEAD = DAY * 365
+)
+
+func toDraft(dbDraft database.ChirpDraft) Draft {
+	draft := Draft{
+		ID:         dbDraft.ID,
+		CreatedAt:  dbDraft.CreatedAt,
+		UpdatedAt:  dbDraft.UpdatedAt,
+		Body:       dbDraft.Body,
+		Visibility: dbDraft.Visibility,
+		MediaIds:   dbDraft.MediaIds,
+		Poll:       draftPoll(dbDraft),
+		LastError:  dbDraft.LastError.String,
+	}
+	if draft.MediaIds == nil {
+		draft.MediaIds = make([]uuid.UUID, 0)
+	}
+	if dbDraft.PublishAt.Valid {
+		draft.PublishAt = &dbDraft.PublishAt.Time
+	}
+
+	return draft
+}
+
+// draftPoll returns the poll stored with a draft, nil if it has none.
+func draftPoll(dbDraft database.ChirpDraft) *PollRequest {
+	if len(dbDraft.PollOptions) == 0 {
+		return nil
+	}
+
+	return &PollRequest{
+		Options:         dbDraft.PollOptions,
+		DurationMinutes: int(dbDraft.PollDurationMinutes),
+		HideResults:     dbDraft.PollHideResults,
+	}
+}
+
+// decodeDraft reads and validates a draft of userId from the request body.
+// Moderation, mentions and media ownership are only checked when it gets
+// published.
+func (ac *apiConfig) decodeDraft(w http.ResponseWriter, r *http.Request, userId uuid.UUID) (DraftRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData DraftRequest
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return DraftRequest{}, false
+	}
+
+	if reqData.Visibility == "" {
+		reqData.Visibility = VISIBILITY_PUBLIC
+	}
+	if reqData.MediaIds == nil {
+		reqData.MediaIds = make([]uuid.UUID, 0)
+	}
+
+	ent, err := ac.entitlements(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return DraftRequest{}, false
+	}
+
+	err = validateChirp(reqData.chirp(), ent)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return DraftRequest{}, false
+	}
+
+	if reqData.PublishAt != nil {
+		now := time.Now().UTC()
+		if !reqData.PublishAt.After(now) {
+			respondWithError(w, 400, "publish_at must be in the future")
+			return DraftRequest{}, false
+		}
+		if reqData.PublishAt.After(now.Add(MAX_SCHEDULE_AHEAD)) {
+			respondWithError(w, 400, "Chirps can be scheduled at most a year ahead")
+			return DraftRequest{}, false
+		}
+	}
+
+	return reqData, true
+}
+
+func (dr DraftRequest) chirp() ChirpBody {
+	return ChirpBody{
+		Body:       dr.Body,
+		Visibility: dr.Visibility,
+		MediaIds:   dr.MediaIds,
+		Poll:       dr.Poll,
+	}
+}
+
+// poll returns the poll of the draft, the zero value storing none.
+func (dr DraftRequest) poll() PollRequest {
+	if dr.Poll == nil {
+		return PollRequest{}
+	}
+
+	return *dr.Poll
+}
+
+func (dr DraftRequest) publishAt() sql.NullTime {
+	if dr.PublishAt == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: dr.PublishAt.UTC(), Valid: true}
+}
+
+func (ac *apiConfig) createDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	reqData, ok := ac.decodeDraft(w, r, userId)
+	if !ok {
+		return
+	}
+
+	poll := reqData.poll()
+	dbDraft, err := ac.Queries.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:     userId,
+		Body:       reqData.Body,
+		Visibility: reqData.Visibility,
+		MediaIds:   reqData.MediaIds,
+		PublishAt:  reqData.publishAt(),
+		// The poll only opens once the chirp is published
+		PollOptions:         poll.Options,
+		PollDurationMinutes: int32(poll.DurationMinutes),
+		PollHideResults:     poll.HideResults,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, toDraft(dbDraft))
+}
+
+// getDraftsHandler lists the caller's drafts, newest first. ?scheduled=true
+// only returns the ones with a publish_at and ?scheduled=false the others.
+func (ac *apiConfig) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	scheduled := sql.NullBool{}
+	switch r.URL.Query().Get("scheduled") {
+	case "":
+	case "true":
+		scheduled = sql.NullBool{Bool: true, Valid: true}
+	case "false":
+		scheduled = sql.NullBool{Bool: false, Valid: true}
+	default:
+		respondWithError(w, 400, "scheduled must be true or false")
+		return
+	}
+
+	dbDrafts, err := ac.Queries.GetDrafts(r.Context(), database.GetDraftsParams{
+		UserID:    userId,
+		Scheduled: scheduled,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	drafts := make([]Draft, 0, len(dbDrafts))
+	for _, dbDraft := range dbDrafts {
+		drafts = append(drafts, toDraft(dbDraft))
+	}
+
+	respondWithJSON(w, 200, drafts)
+}
+
+// draftTarget authenticates the request and parses its {draftID}. Drafts of
+// other users are reported as not found by the queries themselves.
+func (ac *apiConfig) draftTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	draftId, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid draft ID format")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userId, draftId, true
+}
+
+func (ac *apiConfig) getDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userId, draftId, ok := ac.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	dbDraft, err := ac.Queries.GetDraftById(r.Context(), database.GetDraftByIdParams{
+		ID:     draftId,
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, toDraft(dbDraft))
+}
+
+// updateDraftHandler replaces a draft. Leaving publish_at out turns a
+// scheduled chirp back into a draft.
+func (ac *apiConfig) updateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userId, draftId, ok := ac.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	reqData, ok := ac.decodeDraft(w, r, userId)
+	if !ok {
+		return
+	}
+
+	poll := reqData.poll()
+	dbDraft, err := ac.Queries.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID:         draftId,
+		UserID:     userId,
+		Body:       reqData.Body,
+		Visibility: reqData.Visibility,
+		MediaIds:   reqData.MediaIds,
+		PublishAt:  reqData.publishAt(),
+		// The poll only opens once the chirp is published
+		PollOptions:         poll.Options,
+		PollDurationMinutes: int32(poll.DurationMinutes),
+		PollHideResults:     poll.HideResults,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, toDraft(dbDraft))
+}
+
+func (ac *apiConfig) deleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userId, draftId, ok := ac.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := ac.Queries.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// publishDraftHandler publishes a draft right away, whether or not it was
+// scheduled.
+func (ac *apiConfig) publishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	userId, draftId, ok := ac.draftTarget(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	// Locks the draft so the scheduler can't publish it at the same time
+	dbDraft, err := qtx.LockDraft(r.Context(), database.LockDraftParams{
+		ID:     draftId,
+		UserID: userId,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Draft not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	chirp, err := ac.publishDraft(r.Context(), qtx, dbDraft)
+	if err != nil {
+		respondWithError(w, chirpErrorStatus(err), err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	ac.emit(r.Context(), EVENT_CHIRP_CREATED, toChirpEvent(chirp))
+
+	resChirp, err := ac.renderChirp(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, chirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, resChirp)
+}
+
+// publishDraft turns a locked draft into a chirp and deletes it, in the
+// caller's transaction.
+func (ac *apiConfig) publishDraft(ctx context.Context, q *database.Queries, dbDraft database.ChirpDraft) (database.Chirp, error) {
+	ent, err := ac.entitlements(ctx, dbDraft.UserID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	chirp, err := ac.publishChirp(ctx, q, dbDraft.UserID, ent, ChirpBody{
+		Body:       dbDraft.Body,
+		Visibility: dbDraft.Visibility,
+		MediaIds:   dbDraft.MediaIds,
+		Poll:       draftPoll(dbDraft),
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	_, err = q.DeleteDraft(ctx, database.DeleteDraftParams{
+		ID:     dbDraft.ID,
+		UserID: dbDraft.UserID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// publishNextDueDraft publishes the oldest due scheduled chirp, if any, and
+// reports whether there was one. The draft row stays locked with SKIP LOCKED
+// until the transaction commits, so with several instances running each due
+// draft is claimed and published by exactly one of them. Drafts that fail
+// validation are turned back into drafts carrying the error.
+func (ac *apiConfig) publishNextDueDraft(ctx context.Context) (bool, error) {
+	tx, err := ac.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	dbDraft, err := qtx.ClaimDueDraft(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	author, err := qtx.GetUserById(ctx, dbDraft.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	var chirp database.Chirp
+	publishErr := errAccountSuspended
+	if !isSuspended(author, time.Now().UTC()) {
+		// A failed publish must not undo the claim, so it gets its own savepoint
+		_, err = tx.ExecContext(ctx, "SAVEPOINT publish_draft")
+		if err != nil {
+			return false, err
+		}
+
+		chirp, publishErr = ac.publishDraft(ctx, qtx, dbDraft)
+		if publishErr != nil && !isChirpValidationError(publishErr) {
+			return false, publishErr
+		}
+		if publishErr != nil {
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT publish_draft")
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if publishErr != nil {
+		err = qtx.FailDraft(ctx, database.FailDraftParams{
+			ID:        dbDraft.ID,
+			LastError: sql.NullString{String: publishErr.Error(), Valid: true},
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	if publishErr == nil {
+		ac.emit(ctx, EVENT_CHIRP_CREATED, toChirpEvent(chirp))
+	}
+
+	return true, nil
+}
+
+// runDraftScheduler publishes due scheduled chirps every DRAFTS_INTERVAL
+// until ctx is done.
+func (ac *apiConfig) runDraftScheduler(ctx context.Context) {
+	ticker := time.NewTicker(DRAFTS_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		for {
+			published, err := ac.publishNextDueDraft(ctx)
+			if err != nil {
+				log.Printf("Error publishing scheduled chirp: %v", err)
+			}
+			if err != nil || !published {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+// editChirpHandler replaces the body of a chirp while its author's plan
+// still allows editing it. The new body goes through the same checks as a
+// new chirp, and its hashtags and mentions are saved again.
+func (ac *apiConfig) editChirpHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, dbChirp, ok := ac.chirpTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if dbChirp.UserID != userId {
+		respondWithError(w, 403, "Cant edit a chirp you didnt write")
+		return
+	}
+
+	if dbChirp.ModerationStatus == CHIRP_HIDDEN {
+		respondWithError(w, 403, "Hidden chirps can't be edited")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData EditChirpRequest
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	ent, err := ac.entitlements(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	if !ent.CanEdit(dbChirp.CreatedAt, time.Now().UTC()) {
+		if ent.EditWindow == 0 {
+			respondWithError(w, 403, "Editing chirps needs Chirpy Red")
+			return
+		}
+		respondWithError(w, 403, fmt.Sprintf("Chirps can only be edited for %v after posting", ent.EditWindow))
+		return
+	}
+
+	if !ac.allowChirp(userId, ent) {
+		respondWithError(w, 429, errChirpRateLimited.Error())
+		return
+	}
+
+	err = validateChirp(ChirpBody{Body: reqData.Body}, ent)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	decision := ac.Moderation.Run(reqData.Body)
+	if decision.Action == moderation.Reject {
+		respondWithError(w, 400, fmt.Sprintf("%v: %s", errChirpRejected, decision.Reason))
+		return
+	}
+
+	// A chirp already waiting for review keeps its report, moderators see
+	// the new body when they get to it
+	status := dbChirp.ModerationStatus
+	needsReview := decision.Action == moderation.Review && status == CHIRP_PUBLISHED
+	if needsReview {
+		status = CHIRP_PENDING_REVIEW
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	q := ac.Queries.WithTx(tx)
+
+	chirp, err := q.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		Body:             decision.Body,
+		ModerationStatus: status,
+		PreviewUrl:       chirpPreviewURL(decision.Body),
+		ID:               dbChirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = saveChirpHashtags(r.Context(), q, chirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	if needsReview {
+		err = queueChirpReview(r.Context(), q, chirp.ID, decision)
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+	}
+
+	err = saveChirpMentions(r.Context(), q, chirp)
+	if err != nil {
+		respondWithError(w, chirpErrorStatus(err), err.Error())
+		return
+	}
+
+	err = queueWebhookDeliveries(r.Context(), q, EVENT_CHIRP_UPDATED, chirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	ac.emit(r.Context(), EVENT_CHIRP_UPDATED, toChirpEvent(chirp))
+
+	resChirp, err := ac.renderChirp(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, chirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, resChirp)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entitlements"
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/google/uuid"
+)
+
+// userPlan returns the plan sub grants at now, the free plan when there is
+// no active subscription.
+func userPlan(sub *database.Subscription, now time.Time) string {
+	if !isActiveSubscription(sub, now) {
+		return entitlements.PlanFree
+	}
+
+	return sub.Plan
+}
+
+// entitlements returns what userId's plan allows them right now. This is the
+// one place handlers go to for plan dependent limits.
+func (ac *apiConfig) entitlements(ctx context.Context, userId uuid.UUID) (entitlements.Entitlements, error) {
+	sub, err := ac.userSubscription(ctx, userId)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+
+	return entitlements.ForPlan(userPlan(sub, time.Now().UTC())), nil
+}
+
+// addBadges sets the badge of users whose plan comes with one, with a single
+// query for all of them.
+func (ac *apiConfig) addBadges(ctx context.Context, users []PublicUser) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	userIds := make([]uuid.UUID, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.ID)
+	}
+
+	plans, err := ac.Queries.GetActivePlansByUserIds(ctx, userIds)
+	if err != nil {
+		return err
+	}
+
+	badges := make(map[uuid.UUID]string, len(plans))
+	for _, plan := range plans {
+		badges[plan.UserID] = entitlements.ForPlan(plan.Plan).Badge
+	}
+
+	for i := range users {
+		users[i].Badge = badges[users[i].ID]
+	}
+
+	return nil
+}
+
+// newChirpLimiters builds a limiter for each plan, keyed by user. Each lets a
+// user post ChirpsPerMinute chirps at once and refills over a minute.
+func newChirpLimiters() map[string]*limiter.Limiter {
+	limiters := make(map[string]*limiter.Limiter)
+	for _, plan := range entitlements.Plans() {
+		perMinute := entitlements.ForPlan(plan).ChirpsPerMinute
+		limiters[plan] = tollbooth.NewLimiter(float64(perMinute)/60, &limiter.ExpirableOptions{
+			DefaultExpirationTTL: time.Hour,
+		}).SetBurst(perMinute)
+	}
+
+	return limiters
+}
+
+// allowChirp takes a token from the bucket of userId, reporting false when
+// they are posting faster than their plan allows.
+func (ac *apiConfig) allowChirp(userId uuid.UUID, ent entitlements.Entitlements) bool {
+	lmt, ok := ac.ChirpLimiters[ent.Plan]
+	if !ok {
+		return true
+	}
+
+	return tollbooth.LimitByKeys(lmt, []string{userId.String()}) == nil
+}
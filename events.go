@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/events"
+)
+
+const (
+	EVENT_CHIRP_CREATED = "chirp.created"
+	EVENT_CHIRP_UPDATED = "chirp.updated"
+	EVENT_CHIRP_DELETED = "chirp.deleted"
+	EVENT_MESSAGE_SENT  = "message.sent"
+	// Admins changed the banned words, every instance reloads them
+	EVENT_BANNED_WORDS_CHANGED = "moderation.banned_words_changed"
+
+	EVENT_BUS_CHANNEL  = "chirpy_events"
+	EVENT_BUS_SEQUENCE = "chirpy_event_ids"
+)
+
+// newEventBus picks the bus from EVENT_BUS: "postgres" to share events with
+// every instance through LISTEN/NOTIFY, in-memory by default for a single
+// instance.
+func newEventBus(db *sql.DB, dbUrl string) (events.Bus, error) {
+	if os.Getenv("EVENT_BUS") == "postgres" {
+		return events.NewPostgres(db, dbUrl, EVENT_BUS_CHANNEL, EVENT_BUS_SEQUENCE)
+	}
+
+	return events.NewMemory(), nil
+}
+
+// emit publishes a domain event once the write it describes is committed.
+// Events are best effort, a failure is logged and doesn't fail the request
+// that already succeeded. Webhook deliveries need to be durable and are
+// stored by the write itself, see queueWebhookDeliveries.
+func (ac *apiConfig) emit(ctx context.Context, eventType string, data any) {
+	event, err := events.New(eventType, data)
+	if err != nil {
+		log.Printf("Error encoding %s event: %v", eventType, err)
+		return
+	}
+
+	// The client going away must not cancel the event
+	err = ac.Events.Publish(context.WithoutCancel(ctx), event)
+	if err != nil {
+		log.Printf("Error publishing %s event: %v", eventType, err)
+	}
+}
+
+// relayEvent hands the events of the bus, from this instance or another, to
+// the local hub the realtime endpoints read from. The hub keeps the ID the
+// bus gave the event, so stream IDs mean the same on every instance.
+// Moderation events update the local state instead.
+func (ac *apiConfig) relayEvent(event events.Event) {
+	switch event.Type {
+	case EVENT_BANNED_WORDS_CHANGED:
+		err := ac.reloadBannedWords(context.Background())
+		if err != nil {
+			log.Printf("Error reloading banned words: %v", err)
+		}
+	case EVENT_CHIRP_CREATED, EVENT_CHIRP_UPDATED, EVENT_CHIRP_DELETED:
+		var chirpEvent ChirpEvent
+		if err := event.Decode(&chirpEvent); err != nil {
+			log.Printf("Error decoding %s event: %v", event.Type, err)
+			return
+		}
+		// Loaded once here rather than by every connection
+		sc, err := ac.newStreamChirp(context.Background(), event.Type, chirpEvent.chirp())
+		if err != nil {
+			log.Printf("Error preparing %s event: %v", event.Type, err)
+			return
+		}
+		ac.Stream.Publish(event.ID, event.Type, sc)
+	case EVENT_MESSAGE_SENT:
+		var messageEvent MessageEvent
+		if err := event.Decode(&messageEvent); err != nil {
+			log.Printf("Error decoding %s event: %v", event.Type, err)
+			return
+		}
+		ac.Stream.Publish(event.ID, event.Type, messageEvent)
+	}
+}
+
+func toChirpEvent(dbChirp database.Chirp) ChirpEvent {
+	chirpEvent := ChirpEvent{
+		ID:               dbChirp.ID,
+		CreatedAt:        dbChirp.CreatedAt,
+		UpdatedAt:        dbChirp.UpdatedAt,
+		Body:             dbChirp.Body,
+		UserID:           dbChirp.UserID,
+		ModerationStatus: dbChirp.ModerationStatus,
+		Visibility:       dbChirp.Visibility,
+		PreviewURL:       dbChirp.PreviewUrl.String,
+	}
+	if dbChirp.EditedAt.Valid {
+		chirpEvent.EditedAt = &dbChirp.EditedAt.Time
+	}
+
+	return chirpEvent
+}
+
+func (e ChirpEvent) chirp() database.Chirp {
+	dbChirp := database.Chirp{
+		ID:               e.ID,
+		CreatedAt:        e.CreatedAt,
+		UpdatedAt:        e.UpdatedAt,
+		Body:             e.Body,
+		UserID:           e.UserID,
+		ModerationStatus: e.ModerationStatus,
+		Visibility:       e.Visibility,
+		PreviewUrl:       sql.NullString{String: e.PreviewURL, Valid: e.PreviewURL != ""},
+	}
+	if e.EditedAt != nil {
+		dbChirp.EditedAt = sql.NullTime{Time: *e.EditedAt, Valid: true}
+	}
+
+	return dbChirp
+}
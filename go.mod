@@ -10,13 +10,18 @@ require github.com/joho/godotenv v1.5.1 // direct
 
 require (
 	github.com/alexedwards/argon2id v1.0.0 // direct
-	golang.org/x/crypto v0.14.0 // indirect
-	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/crypto v0.38.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
 )
 
 require github.com/golang-jwt/jwt/v5 v5.3.0 // direct
 
 require (
-	github.com/didip/tollbooth/v7 v7.0.2 // indirect
-	github.com/go-pkgz/expirable-cache/v3 v3.0.0 // indirect
+	github.com/didip/tollbooth/v7 v7.0.2
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/image v0.27.0
+	golang.org/x/net v0.40.0
+	golang.org/x/text v0.25.0
 )
+
+require github.com/go-pkgz/expirable-cache/v3 v3.0.0 // indirect
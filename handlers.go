@@ -1,18 +1,34 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/Alb3G/chirpy/internal/auth"
 	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entities"
+	"github.com/Alb3G/chirpy/internal/entitlements"
+	"github.com/Alb3G/chirpy/internal/moderation"
 	"github.com/google/uuid"
 )
 
+var (
+	errChirpTooLong      = errors.New("chirp is too long")
+	errTooManyMedia      = errors.New("too many media attachments")
+	errChirpRateLimited  = errors.New("posting too fast, try again in a bit")
+	errInvalidVisibility = errors.New("visibility must be one of public, followers or mentioned")
+	errChirpRejected     = errors.New("chirp rejected")
+)
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, 200, struct {
 		Result    bool      `json:"alive"`
@@ -99,13 +115,35 @@ func (ac *apiConfig) usersHandler(w http.ResponseWriter, r *http.Request) {
 		HashedPass: hash,
 	}
 
+	if userReqdata.Handle != "" {
+		handle := entities.NormalizeHandle(userReqdata.Handle)
+		if !entities.ValidHandle(handle) {
+			respondWithError(w, 400, "Handle must be 1 to 30 letters, digits or underscores")
+			return
+		}
+		userParams.Handle = sql.NullString{String: handle, Valid: true}
+	}
+
+	if userReqdata.DisplayName != "" {
+		displayName := strings.TrimSpace(userReqdata.DisplayName)
+		if utf8.RuneCountInString(displayName) > MAX_DISPLAY_NAME_LENGTH {
+			respondWithError(w, 400, "Display name is too long")
+			return
+		}
+		userParams.DisplayName = sql.NullString{String: displayName, Valid: true}
+	}
+
 	dbUser, err := ac.Queries.CreateUser(r.Context(), userParams)
+	if isUniqueViolation(err, USERS_HANDLE_CONSTRAINT) {
+		respondWithError(w, 409, "Handle already taken")
+		return
+	}
 	if err != nil {
 		respondWithError(w, 400, "Account already exists")
 		return
 	}
 
-	domain_user, err := toUser(dbUser, nil)
+	domain_user, err := toUser(dbUser, nil, nil)
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
@@ -138,29 +176,179 @@ func (ac *apiConfig) chirpsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user_uuid, err := auth.ValidateJWT(token, ac.TokenSecret)
+	user_uuid, err := ac.validateAccessToken(r.Context(), token)
 	if err != nil {
 		respondWithError(w, 401, err.Error())
 		return
 	}
 
-	if len(reqData.Body) > 140 {
-		respondWithError(w, http.StatusBadRequest, "Something went wrong")
+	ent, err := ac.entitlements(r.Context(), user_uuid)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
 		return
 	}
 
-	validatedBody := validateChirpBody(reqData.Body, []string{"kerfuffle", "sharbert", "fornax"})
+	if !ac.allowChirp(user_uuid, ent) {
+		respondWithError(w, 429, errChirpRateLimited.Error())
+		return
+	}
 
-	chirp, err := ac.Queries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   validatedBody,
-		UserID: user_uuid,
-	})
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
 	}
+	defer tx.Rollback()
+
+	chirp, err := ac.publishChirp(r.Context(), ac.Queries.WithTx(tx), user_uuid, ent, reqData)
+	if err != nil {
+		respondWithError(w, chirpErrorStatus(err), err.Error())
+		return
+	}
 
-	respondWithJSON(w, 201, toChirp(chirp))
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	ac.emit(r.Context(), EVENT_CHIRP_CREATED, toChirpEvent(chirp))
+
+	resChirp, err := ac.renderChirp(r.Context(), uuid.NullUUID{UUID: user_uuid, Valid: true}, chirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, resChirp)
+}
+
+// validateChirp runs the checks that don't need the database, so drafts can
+// fail early. They run again when the chirp is published, against the
+// author's entitlements at that point.
+func validateChirp(reqData ChirpBody, ent entitlements.Entitlements) error {
+	if utf8.RuneCountInString(reqData.Body) > ent.MaxChirpLength {
+		return fmt.Errorf("%w, the limit is %d characters", errChirpTooLong, ent.MaxChirpLength)
+	}
+
+	if len(reqData.MediaIds) > ent.MaxChirpMedia {
+		return fmt.Errorf("%w, the limit is %d", errTooManyMedia, ent.MaxChirpMedia)
+	}
+
+	if reqData.Visibility != "" && !chirpVisibilities[reqData.Visibility] {
+		return errInvalidVisibility
+	}
+
+	return validatePoll(reqData.Poll)
+}
+
+// publishChirp validates, moderates and stores a new chirp of userId along
+// with its hashtags, mentions and media, within ent, the author's current
+// entitlements. q should be bound to a transaction the caller commits. Both
+// POST /api/chirps and the draft scheduler go through here so every chirp
+// gets the same checks.
+func (ac *apiConfig) publishChirp(ctx context.Context, q *database.Queries, userId uuid.UUID, ent entitlements.Entitlements, reqData ChirpBody) (database.Chirp, error) {
+	err := validateChirp(reqData, ent)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	visibility := reqData.Visibility
+	if visibility == "" {
+		visibility = VISIBILITY_PUBLIC
+	}
+
+	decision := ac.Moderation.Run(reqData.Body)
+	if decision.Action == moderation.Reject {
+		return database.Chirp{}, fmt.Errorf("%w: %s", errChirpRejected, decision.Reason)
+	}
+
+	status := CHIRP_PUBLISHED
+	if decision.Action == moderation.Review {
+		status = CHIRP_PENDING_REVIEW
+	}
+
+	chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body:             decision.Body,
+		UserID:           userId,
+		ModerationStatus: status,
+		Visibility:       visibility,
+		PreviewUrl:       chirpPreviewURL(decision.Body),
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = saveChirpHashtags(ctx, q, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if status == CHIRP_PENDING_REVIEW {
+		err = queueChirpReview(ctx, q, chirp.ID, decision)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	err = saveChirpMentions(ctx, q, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = attachChirpMedia(ctx, q, chirp, reqData.MediaIds)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = savePoll(ctx, q, chirp, reqData.Poll)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	err = queueWebhookDeliveries(ctx, q, EVENT_CHIRP_CREATED, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// queueChirpReview files the automated report that puts a chirp held by
+// moderation in front of the moderators.
+func queueChirpReview(ctx context.Context, q *database.Queries, chirpId uuid.UUID, decision moderation.Decision) error {
+	_, err := q.CreateReport(ctx, database.CreateReportParams{
+		TargetType: REPORT_TARGET_CHIRP,
+		TargetID:   chirpId,
+		Reason:     REPORT_REASON_AUTOMATED,
+		Details:    decision.Filter + ": " + decision.Reason,
+	})
+
+	return err
+}
+
+// chirpErrorStatus maps the errors of publishChirp to a response status.
+func chirpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errMentionBlocked):
+		return 403
+	case isChirpValidationError(err):
+		return 400
+	default:
+		return 500
+	}
+}
+
+// isChirpValidationError reports whether err means the chirp itself is not
+// acceptable, as opposed to something going wrong while storing it.
+func isChirpValidationError(err error) bool {
+	return errors.Is(err, errChirpTooLong) ||
+		errors.Is(err, errTooManyMedia) ||
+		errors.Is(err, errInvalidVisibility) ||
+		errors.Is(err, errInvalidPoll) ||
+		errors.Is(err, errChirpRejected) ||
+		errors.Is(err, errMentionBlocked) ||
+		errors.Is(err, errInvalidMedia)
 }
 
 func getUserChirps(w http.ResponseWriter, r *http.Request, ac *apiConfig, author_id string) {
@@ -170,15 +358,40 @@ func getUserChirps(w http.ResponseWriter, r *http.Request, ac *apiConfig, author
 		return
 	}
 
-	userChirps := make([]Chirp, 0)
 	dbChirps, err := ac.Queries.GetChirpsByUserId(r.Context(), parsed_uuid)
 	if err != nil {
 		respondWithError(w, 400, err.Error())
 		return
 	}
 
-	for _, dbChirp := range dbChirps {
-		userChirps = append(userChirps, toChirp(dbChirp))
+	viewer := ac.viewerId(r)
+	dbChirps, err = ac.filterChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	userChirps, err := ac.renderChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	author, err := ac.Queries.GetUserById(r.Context(), parsed_uuid)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	// The pinned chirp goes first, if the viewer can see it
+	if author.PinnedChirpID.Valid {
+		for i, chirp := range userChirps {
+			if chirp.ID == author.PinnedChirpID.UUID {
+				chirp.Pinned = true
+				userChirps = append([]Chirp{chirp}, slices.Delete(userChirps, i, i+1)...)
+				break
+			}
+		}
 	}
 
 	respondWithJSON(w, 200, userChirps)
@@ -199,9 +412,17 @@ func (ac *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	resChirpsArr := make([]Chirp, 0)
-	for _, dbChirp := range dbChirps {
-		resChirpsArr = append(resChirpsArr, toChirp(dbChirp))
+	viewer := ac.viewerId(r)
+	dbChirps, err = ac.filterChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	resChirpsArr, err := ac.renderChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
 	}
 
 	order_arg := r.URL.Query().Get("sort")
@@ -236,7 +457,25 @@ func (ac *apiConfig) getChirpById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondWithJSON(w, 200, toChirp(dbChirp))
+	viewer := ac.viewerId(r)
+	visible, err := ac.canSeeChirp(r.Context(), viewer, dbChirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	if !visible {
+		respondWithError(w, 404, "Chirp Not found!")
+		return
+	}
+
+	chirp, err := ac.renderChirp(r.Context(), viewer, dbChirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, chirp)
 }
 
 /*
@@ -274,13 +513,24 @@ func (ac *apiConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isSuspended(dbUser, time.Now().UTC()) {
+		respondWithError(w, 403, "Account suspended")
+		return
+	}
+
 	token, err := auth.MakeJWT(dbUser.ID, ac.TokenSecret, time.Second*3600)
 	if err != nil {
 		respondWithError(w, 500, "Error while creating JWT")
 		return
 	}
 
-	domainUser, err := toUser(dbUser, &token)
+	sub, err := ac.userSubscription(r.Context(), dbUser.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	domainUser, err := toUser(dbUser, sub, &token)
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
@@ -335,6 +585,11 @@ func (ac *apiConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if isSuspended(user, time.Now().UTC()) {
+		respondWithError(w, 403, "Account suspended")
+		return
+	}
+
 	newJwt, err := auth.MakeJWT(user.ID, ac.TokenSecret, time.Hour)
 	if err != nil {
 		respondWithError(w, 401, err.Error())
@@ -373,7 +628,7 @@ func (ac *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(accessToken, ac.TokenSecret)
+	userID, err := ac.validateAccessToken(r.Context(), accessToken)
 	if err != nil {
 		respondWithError(w, 401, "Invalid Access token")
 		return
@@ -401,13 +656,45 @@ func (ac *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
 		ID:         userID,
 	}
 
+	if body.Handle != "" {
+		handle := entities.NormalizeHandle(body.Handle)
+		if !entities.ValidHandle(handle) {
+			respondWithError(w, 400, "Handle must be 1 to 30 letters, digits or underscores")
+			return
+		}
+		dbUpdateUserParams.Handle = sql.NullString{String: handle, Valid: true}
+	}
+
+	if body.DisplayName != "" {
+		displayName := strings.TrimSpace(body.DisplayName)
+		if utf8.RuneCountInString(displayName) > MAX_DISPLAY_NAME_LENGTH {
+			respondWithError(w, 400, "Display name is too long")
+			return
+		}
+		dbUpdateUserParams.DisplayName = sql.NullString{String: displayName, Valid: true}
+	}
+
+	if body.AcceptsDms != nil {
+		dbUpdateUserParams.AcceptsDms = sql.NullBool{Bool: *body.AcceptsDms, Valid: true}
+	}
+
 	updatedUser, err := ac.Queries.UpdateUser(r.Context(), dbUpdateUserParams)
+	if isUniqueViolation(err, USERS_HANDLE_CONSTRAINT) {
+		respondWithError(w, 409, "Handle already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	sub, err := ac.userSubscription(r.Context(), updatedUser.ID)
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
 	}
 
-	domainUser, err := toUser(updatedUser, &accessToken)
+	domainUser, err := toUser(updatedUser, sub, &accessToken)
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
@@ -423,7 +710,7 @@ func (ac *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userUUID, err := auth.ValidateJWT(accessToken, ac.TokenSecret)
+	userUUID, err := ac.validateAccessToken(r.Context(), accessToken)
 	if err != nil {
 		respondWithError(w, 403, err.Error())
 		return
@@ -446,42 +733,34 @@ func (ac *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ac.Queries.DeleteChirpById(r.Context(), chirpID)
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
 	if err != nil {
 		respondWithError(w, 500, err.Error())
 		return
 	}
+	defer tx.Rollback()
 
-	respondWithJSON(w, 204, struct{}{})
-}
-
-func (ac *apiConfig) upgradeUser(w http.ResponseWriter, r *http.Request) {
-	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+	qtx := ac.Queries.WithTx(tx)
 
-	if apiKey, err := auth.GetApiKey(r.Header); err != nil || apiKey != ac.Key {
-		respondWithError(w, 401, err.Error())
+	err = qtx.DeleteChirpById(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
 		return
 	}
 
-	var upgradeRequest UpgradeRequest
-	decoder := json.NewDecoder(r.Body)
-	decoder.Decode(&upgradeRequest)
-
-	if upgradeRequest.Event != "user.upgraded" {
-		respondWithJSON(w, 204, struct{}{})
+	err = queueWebhookDeliveries(r.Context(), qtx, EVENT_CHIRP_DELETED, chirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
 		return
 	}
 
-	success, err := ac.Queries.UpgradeUserById(r.Context(), upgradeRequest.Data.UserId)
+	err = tx.Commit()
 	if err != nil {
-		respondWithError(w, 404, err.Error())
+		respondWithError(w, 500, err.Error())
 		return
 	}
 
-	if success == 0 {
-		respondWithError(w, 404, "User not found")
-		return
-	}
+	ac.emit(r.Context(), EVENT_CHIRP_DELETED, toChirpEvent(chirp))
 
 	respondWithJSON(w, 204, struct{}{})
 }
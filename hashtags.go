@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entities"
+)
+
+const (
+	TRENDS_INTERVAL  = time.Minute * 5
+	TRENDS_WINDOW    = DAY
+	TRENDS_HALF_LIFE = time.Hour * 6
+	TRENDS_LIMIT     = 10
+)
+
+// trendsCache holds the last ranking computed by the trends job so that
+// GET /api/trends never has to hit the database.
+type trendsCache struct {
+	mu         sync.RWMutex
+	trends     []Trend
+	computedAt time.Time
+}
+
+func (tc *trendsCache) get() ([]Trend, time.Time) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+
+	return tc.trends, tc.computedAt
+}
+
+func (tc *trendsCache) set(trends []Trend, computedAt time.Time) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.trends = trends
+	tc.computedAt = computedAt
+}
+
+// saveChirpHashtags replaces the hashtags linked to a chirp with the ones
+// currently found in its body, so it can be used after creates and edits.
+func saveChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpHashtags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range entities.UniqueTags(entities.ExtractHashtags(chirp.Body)) {
+		hashtag, err := q.UpsertHashtag(ctx, tag)
+		if err != nil {
+			return err
+		}
+
+		err = q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			HashtagID: hashtag.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ac *apiConfig) getHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, 400, "Invalid hashtag")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetChirpsByHashtagParams{
+		Tag:        tag,
+		MaxResults: limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID.UUID, params.BeforeID.Valid = id, true
+	}
+
+	dbChirps, err := ac.Queries.GetChirpsByHashtag(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	dbChirps, nextCursor := paginateChirps(dbChirps, limit)
+
+	viewer := ac.viewerId(r)
+	dbChirps, err = ac.filterChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	chirps, err := ac.renderChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+	})
+}
+
+func (ac *apiConfig) getTrendsHandler(w http.ResponseWriter, r *http.Request) {
+	trends, computedAt := ac.trends.get()
+	if trends == nil {
+		trends = make([]Trend, 0)
+	}
+
+	respondWithJSON(w, 200, struct {
+		Trends     []Trend   `json:"trends"`
+		ComputedAt time.Time `json:"computed_at"`
+	}{
+		Trends:     trends,
+		ComputedAt: computedAt,
+	})
+}
+
+func (ac *apiConfig) computeTrends(ctx context.Context) error {
+	rows, err := ac.Queries.GetHashtagTrends(ctx, database.GetHashtagTrendsParams{
+		HalfLifeSeconds: TRENDS_HALF_LIFE.Seconds(),
+		Since:           time.Now().UTC().Add(-TRENDS_WINDOW),
+		MaxResults:      TRENDS_LIMIT,
+	})
+	if err != nil {
+		return err
+	}
+
+	trends := make([]Trend, 0, len(rows))
+	for _, row := range rows {
+		trends = append(trends, Trend{
+			Tag:   row.Tag,
+			Uses:  row.Uses,
+			Score: row.Score,
+		})
+	}
+
+	ac.trends.set(trends, time.Now().UTC())
+
+	return nil
+}
+
+// runTrendsJob recomputes the trending hashtags every TRENDS_INTERVAL until
+// ctx is cancelled.
+func (ac *apiConfig) runTrendsJob(ctx context.Context) {
+	ticker := time.NewTicker(TRENDS_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := ac.computeTrends(ctx); err != nil {
+			log.Printf("Error computing trends: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -46,20 +46,37 @@ func MakeJWT(userId uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	userId, _, err := ParseJWT(tokenString, tokenSecret)
+	return userId, err
+}
+
+// ParseJWT validates a token like ValidateJWT and also returns when it
+// expires, for connections that outlive the token they were opened with.
+func ParseJWT(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
 	claims := jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
+	}
+
+	subject, err := token.Claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
 	}
 
-	userId, err := token.Claims.GetSubject()
+	userId, err := uuid.Parse(subject)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return uuid.Nil, time.Time{}, errors.New("token has no expiration time")
 	}
 
-	return uuid.MustParse(userId), nil
+	return userId, expiresAt.Time, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -85,14 +102,3 @@ func MakeRefreshToken() string {
 
 	return token
 }
-
-func GetApiKey(headers http.Header) (string, error) {
-	auth_header := headers.Get("Authorization")
-
-	apikey := strings.Split(auth_header, " ")
-	if len(apikey) != 2 {
-		return "", errors.New("invalid api key format")
-	}
-
-	return apikey[1], nil
-}
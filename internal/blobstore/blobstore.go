@@ -0,0 +1,22 @@
+// Package blobstore stores opaque binary objects, such as uploaded media,
+// under string keys.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Get when there is no object under the key.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore is implemented by every storage backend. Keys are slash
+// separated paths like "media/<id>/original".
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the object's contents. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
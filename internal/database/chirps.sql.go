@@ -7,22 +7,32 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-values (gen_random_uuid(), NOW(), NOW(), $1, $2) RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5) RETURNING id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url, edited_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body             string
+	UserID           uuid.UUID
+	ModerationStatus string
+	Visibility       string
+	PreviewUrl       sql.NullString
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ModerationStatus,
+		arg.Visibility,
+		arg.PreviewUrl,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -30,6 +40,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ModerationStatus,
+		&i.Visibility,
+		&i.PreviewUrl,
+		&i.EditedAt,
 	)
 	return i, err
 }
@@ -45,7 +59,7 @@ func (q *Queries) DeleteChirpById(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url, edited_at FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -57,12 +71,16 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ModerationStatus,
+		&i.Visibility,
+		&i.PreviewUrl,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at
+SELECT id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url, edited_at FROM chirps ORDER BY created_at
 `
 
 func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
@@ -80,6 +98,10 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ModerationStatus,
+			&i.Visibility,
+			&i.PreviewUrl,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -95,7 +117,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserId = `-- name: GetChirpsByUserId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url, edited_at FROM chirps
 WHERE user_id = $1
 ORDER BY created_at
 `
@@ -115,6 +137,10 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ModerationStatus,
+			&i.Visibility,
+			&i.PreviewUrl,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -128,3 +154,55 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 	}
 	return items, nil
 }
+
+const setChirpModerationStatus = `-- name: SetChirpModerationStatus :exec
+UPDATE chirps
+SET moderation_status = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetChirpModerationStatusParams struct {
+	ModerationStatus string
+	ID               uuid.UUID
+}
+
+func (q *Queries) SetChirpModerationStatus(ctx context.Context, arg SetChirpModerationStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpModerationStatus, arg.ModerationStatus, arg.ID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, moderation_status = $2, preview_url = $3, edited_at = NOW(), updated_at = NOW()
+WHERE id = $4
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url, edited_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body             string
+	ModerationStatus string
+	PreviewUrl       sql.NullString
+	ID               uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody,
+		arg.Body,
+		arg.ModerationStatus,
+		arg.PreviewUrl,
+		arg.ID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.Visibility,
+		&i.PreviewUrl,
+		&i.EditedAt,
+	)
+	return i, err
+}
@@ -73,7 +73,7 @@ func (q *Queries) GetToken(ctx context.Context, token string) (RefreshToken, err
 }
 
 const getUserByToken = `-- name: GetUserByToken :one
-SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_pass, users.is_chirpy_red FROM users
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_pass, users.handle, users.display_name, users.is_admin, users.suspended_at, users.suspended_until, users.shadow_banned, users.pinned_chirp_id, users.accepts_dms FROM users
 INNER JOIN refresh_tokens 
 ON users.id = refresh_tokens.user_id
 WHERE token = $1
@@ -88,7 +88,14 @@ func (q *Queries) GetUserByToken(ctx context.Context, token string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPass,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.PinnedChirpID,
+		&i.AcceptsDms,
 	)
 	return i, err
 }
@@ -103,3 +110,14 @@ func (q *Queries) RevokeToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeToken, token)
 	return err
 }
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserTokens, userID)
+	return err
+}
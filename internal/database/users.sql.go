@@ -7,22 +7,81 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const autocompleteHandles = `-- name: AutocompleteHandles :many
+SELECT id, handle, display_name, created_at FROM users
+WHERE handle LIKE $1::text || '%'
+AND (suspended_at IS NULL OR suspended_until <= NOW())
+AND NOT shadow_banned
+ORDER BY handle
+LIMIT $2
+`
+
+type AutocompleteHandlesParams struct {
+	HandlePrefix string
+	MaxResults   int32
+}
+
+type AutocompleteHandlesRow struct {
+	ID          uuid.UUID
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	CreatedAt   time.Time
+}
+
+func (q *Queries) AutocompleteHandles(ctx context.Context, arg AutocompleteHandlesParams) ([]AutocompleteHandlesRow, error) {
+	rows, err := q.db.QueryContext(ctx, autocompleteHandles, arg.HandlePrefix, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AutocompleteHandlesRow
+	for rows.Next() {
+		var i AutocompleteHandlesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_pass)
-values (gen_random_uuid(), NOW(), NOW(), $1, $2) RETURNING id, created_at, updated_at, email, hashed_pass, is_chirpy_red
+INSERT INTO users (id, created_at, updated_at, email, hashed_pass, handle, display_name)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4) RETURNING id, created_at, updated_at, email, hashed_pass, handle, display_name, is_admin, suspended_at, suspended_until, shadow_banned, pinned_chirp_id, accepts_dms
 `
 
 type CreateUserParams struct {
-	Email      string
-	HashedPass string
+	Email       string
+	HashedPass  string
+	Handle      sql.NullString
+	DisplayName sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPass)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Email,
+		arg.HashedPass,
+		arg.Handle,
+		arg.DisplayName,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -30,7 +89,14 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPass,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.PinnedChirpID,
+		&i.AcceptsDms,
 	)
 	return i, err
 }
@@ -44,8 +110,36 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const getShadowBannedUserIds = `-- name: GetShadowBannedUserIds :many
+SELECT id FROM users
+WHERE id = ANY($1::uuid[]) AND shadow_banned
+`
+
+func (q *Queries) GetShadowBannedUserIds(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getShadowBannedUserIds, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_pass, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_pass, handle, display_name, is_admin, suspended_at, suspended_until, shadow_banned, pinned_chirp_id, accepts_dms FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -57,26 +151,224 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPass,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.PinnedChirpID,
+		&i.AcceptsDms,
 	)
 	return i, err
 }
 
+const getUserById = `-- name: GetUserById :one
+SELECT id, created_at, updated_at, email, hashed_pass, handle, display_name, is_admin, suspended_at, suspended_until, shadow_banned, pinned_chirp_id, accepts_dms FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserById, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPass,
+		&i.Handle,
+		&i.DisplayName,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.PinnedChirpID,
+		&i.AcceptsDms,
+	)
+	return i, err
+}
+
+const getUsersByHandles = `-- name: GetUsersByHandles :many
+SELECT id, created_at, updated_at, email, hashed_pass, handle, display_name, is_admin, suspended_at, suspended_until, shadow_banned, pinned_chirp_id, accepts_dms FROM users
+WHERE handle = ANY($1::text[])
+`
+
+func (q *Queries) GetUsersByHandles(ctx context.Context, handles []string) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByHandles, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPass,
+			&i.Handle,
+			&i.DisplayName,
+			&i.IsAdmin,
+			&i.SuspendedAt,
+			&i.SuspendedUntil,
+			&i.ShadowBanned,
+			&i.PinnedChirpID,
+			&i.AcceptsDms,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, handle, display_name, created_at FROM users
+WHERE (
+	handle % $1::text
+	OR display_name % $1::text
+	OR handle LIKE $2::text || '%'
+)
+AND (suspended_at IS NULL OR suspended_until <= NOW())
+AND NOT shadow_banned
+ORDER BY GREATEST(
+	similarity(COALESCE(handle, ''), $1::text),
+	similarity(COALESCE(display_name, ''), $1::text)
+) DESC, handle
+LIMIT $3
+`
+
+type SearchUsersParams struct {
+	Query        string
+	HandlePrefix string
+	MaxResults   int32
+}
+
+type SearchUsersRow struct {
+	ID          uuid.UUID
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	CreatedAt   time.Time
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers, arg.Query, arg.HandlePrefix, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUsersRow
+	for rows.Next() {
+		var i SearchUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+			&i.DisplayName,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserShadowBanned = `-- name: SetUserShadowBanned :execrows
+UPDATE users
+SET shadow_banned = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SetUserShadowBannedParams struct {
+	ShadowBanned bool
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserShadowBanned(ctx context.Context, arg SetUserShadowBannedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setUserShadowBanned, arg.ShadowBanned, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const suspendUser = `-- name: SuspendUser :execrows
+UPDATE users
+SET suspended_at = NOW(), suspended_until = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unsuspendUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_pass = $2, updated_at = NOW()
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_pass, is_chirpy_red
+SET email = $1,
+	hashed_pass = $2,
+	handle = COALESCE($3, handle),
+	display_name = COALESCE($4, display_name),
+	accepts_dms = COALESCE($5, accepts_dms),
+	updated_at = NOW()
+WHERE id = $6
+RETURNING id, created_at, updated_at, email, hashed_pass, handle, display_name, is_admin, suspended_at, suspended_until, shadow_banned, pinned_chirp_id, accepts_dms
 `
 
 type UpdateUserParams struct {
-	Email      string
-	HashedPass string
-	ID         uuid.UUID
+	Email       string
+	HashedPass  string
+	Handle      sql.NullString
+	DisplayName sql.NullString
+	AcceptsDms  sql.NullBool
+	ID          uuid.UUID
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPass, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPass,
+		arg.Handle,
+		arg.DisplayName,
+		arg.AcceptsDms,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -84,7 +376,49 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPass,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.IsAdmin,
+		&i.SuspendedAt,
+		&i.SuspendedUntil,
+		&i.ShadowBanned,
+		&i.PinnedChirpID,
+		&i.AcceptsDms,
 	)
 	return i, err
 }
+
+const pinChirp = `-- name: PinChirp :exec
+UPDATE users
+SET pinned_chirp_id = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type PinChirpParams struct {
+	PinnedChirpID uuid.NullUUID
+	ID            uuid.UUID
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) error {
+	_, err := q.db.ExecContext(ctx, pinChirp, arg.PinnedChirpID, arg.ID)
+	return err
+}
+
+const unpinChirp = `-- name: UnpinChirp :execrows
+UPDATE users
+SET pinned_chirp_id = NULL, updated_at = NOW()
+WHERE id = $1 AND pinned_chirp_id = $2
+`
+
+type UnpinChirpParams struct {
+	ID            uuid.UUID
+	PinnedChirpID uuid.NullUUID
+}
+
+func (q *Queries) UnpinChirp(ctx context.Context, arg UnpinChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unpinChirp, arg.ID, arg.PinnedChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,146 @@
+package entities
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	MaxHashtagLength = 100
+	MaxHandleLength  = 30
+)
+
+type Hashtag struct {
+	Tag   string
+	Start int
+	End   int
+}
+
+type Mention struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// ExtractHashtags returns every #hashtag in body along with its byte offsets.
+// Tags are lowercased so that #Go and #go end up as the same hashtag.
+func ExtractHashtags(body string) []Hashtag {
+	hashtags := make([]Hashtag, 0)
+
+	for i := 0; i < len(body); i++ {
+		if body[i] != '#' || !isBoundary(body, i) {
+			continue
+		}
+
+		end := scanWord(body, i+1)
+		word := body[i+1 : end]
+		if word == "" || len(word) > MaxHashtagLength || !strings.ContainsFunc(word, unicode.IsLetter) {
+			continue
+		}
+
+		hashtags = append(hashtags, Hashtag{
+			Tag:   strings.ToLower(word),
+			Start: i,
+			End:   end,
+		})
+		i = end - 1
+	}
+
+	return hashtags
+}
+
+// UniqueTags returns the distinct tags of hashtags keeping their first-seen order.
+func UniqueTags(hashtags []Hashtag) []string {
+	seen := make(map[string]bool)
+	tags := make([]string, 0, len(hashtags))
+
+	for _, hashtag := range hashtags {
+		if seen[hashtag.Tag] {
+			continue
+		}
+		seen[hashtag.Tag] = true
+		tags = append(tags, hashtag.Tag)
+	}
+
+	return tags
+}
+
+// ExtractMentions returns every @handle in body along with its byte offsets.
+// Handles are lowercased; whether they belong to a real user is up to the caller.
+func ExtractMentions(body string) []Mention {
+	mentions := make([]Mention, 0)
+
+	for i := 0; i < len(body); i++ {
+		if body[i] != '@' || !isBoundary(body, i) {
+			continue
+		}
+
+		end := scanWord(body, i+1)
+		handle := body[i+1 : end]
+		if !ValidHandle(handle) {
+			continue
+		}
+
+		mentions = append(mentions, Mention{
+			Handle: strings.ToLower(handle),
+			Start:  i,
+			End:    end,
+		})
+		i = end - 1
+	}
+
+	return mentions
+}
+
+// ValidHandle reports whether handle is 1 to 30 ASCII letters, digits or underscores.
+func ValidHandle(handle string) bool {
+	if handle == "" || len(handle) > MaxHandleLength {
+		return false
+	}
+
+	for _, r := range handle {
+		if r != '_' && (r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NormalizeHandle turns user input like "@Alice" into the stored form "alice".
+func NormalizeHandle(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+}
+
+// NormalizeTag turns user input like "#GoLang" into the stored form "golang".
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isBoundary reports whether the sigil at position i starts a new token, so
+// that things like "c#" or "foo@bar" are not picked up.
+func isBoundary(body string, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	prev, _ := utf8.DecodeLastRuneInString(body[:i])
+	return !isWordRune(prev) && prev != '#' && prev != '@'
+}
+
+func scanWord(body string, start int) int {
+	end := start
+	for end < len(body) {
+		r, size := utf8.DecodeRuneInString(body[end:])
+		if !isWordRune(r) {
+			break
+		}
+		end += size
+	}
+	return end
+}
@@ -0,0 +1,61 @@
+package entitlements
+
+import "time"
+
+const (
+	PlanFree = "free"
+	PlanRed  = "red"
+)
+
+// Entitlements is what a plan allows its members to do. Handlers check these
+// instead of looking at the plan itself, so a new plan only has to be added
+// here.
+type Entitlements struct {
+	Plan           string
+	MaxChirpLength int
+	MaxChirpMedia  int
+	// How long after posting a chirp can still be edited, none when zero.
+	EditWindow time.Duration
+	// Chirps a user can post in a burst, refilled over a minute.
+	ChirpsPerMinute int
+	// Shown next to the user's name, empty for no badge.
+	Badge string
+}
+
+var plans = map[string]Entitlements{
+	PlanFree: {
+		Plan:            PlanFree,
+		MaxChirpLength:  140,
+		MaxChirpMedia:   4,
+		ChirpsPerMinute: 10,
+	},
+	PlanRed: {
+		Plan:            PlanRed,
+		MaxChirpLength:  1000,
+		MaxChirpMedia:   10,
+		EditWindow:      time.Minute * 30,
+		ChirpsPerMinute: 60,
+		Badge:           "chirpy_red",
+	},
+}
+
+// ForPlan returns the entitlements of plan. Unknown plans get the free ones,
+// so a plan Polka knows about and Chirpy doesn't yet grants nothing extra.
+func ForPlan(plan string) Entitlements {
+	if e, ok := plans[plan]; ok {
+		return e
+	}
+
+	return plans[PlanFree]
+}
+
+// Plans returns every known plan.
+func Plans() []string {
+	return []string{PlanFree, PlanRed}
+}
+
+// CanEdit reports whether a chirp posted at createdAt can still be edited at
+// now.
+func (e Entitlements) CanEdit(createdAt, now time.Time) bool {
+	return e.EditWindow > 0 && now.Sub(createdAt) <= e.EditWindow
+}
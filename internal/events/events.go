@@ -0,0 +1,46 @@
+// Package events moves domain events between Chirpy instances. Every
+// instance subscribed to a bus sees every event published on it, its own
+// included, so realtime features and caches can react to writes made on any
+// node behind the load balancer.
+package events
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type Event struct {
+	// ID is assigned by the bus when the event is published and increases
+	// with every event. Every instance sees the same ID for an event, so it
+	// can be handed to clients to resume from on any of them.
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// New builds an event with data encoded as JSON.
+func New(eventType string, data any) (Event, error) {
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{Type: eventType, Data: dat}, nil
+}
+
+// Decode unmarshals the data of the event into v.
+func (e Event) Decode(v any) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// Handler is called for every event received. Handlers should return quickly,
+// a bus delivers events to them one at a time.
+type Handler func(Event)
+
+type Bus interface {
+	// Publish assigns the event its ID and sends it to every subscriber.
+	Publish(ctx context.Context, event Event) error
+	// Subscribe registers handler until the returned function is called.
+	Subscribe(handler Handler) (unsubscribe func())
+	Close() error
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Memory is a bus local to the process, for running a single instance.
+type Memory struct {
+	handlers handlers
+	lastID   atomic.Uint64
+}
+
+// NewMemory creates a bus whose IDs start from the creation time, so they
+// keep increasing across restarts.
+func NewMemory() *Memory {
+	m := &Memory{}
+	m.lastID.Store(uint64(time.Now().UnixMicro()))
+
+	return m
+}
+
+// Publish delivers event to every handler before returning.
+func (m *Memory) Publish(ctx context.Context, event Event) error {
+	event.ID = m.lastID.Add(1)
+	m.handlers.dispatch(event)
+	return nil
+}
+
+func (m *Memory) Subscribe(handler Handler) func() {
+	return m.handlers.add(handler)
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// handlers is the subscriber list shared by the implementations.
+type handlers struct {
+	mu     sync.RWMutex
+	nextID int
+	list   map[int]Handler
+}
+
+func (h *handlers) add(handler Handler) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.list == nil {
+		h.list = make(map[int]Handler)
+	}
+	id := h.nextID
+	h.nextID++
+	h.list[id] = handler
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.list, id)
+	}
+}
+
+func (h *handlers) dispatch(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, handler := range h.list {
+		handler(event)
+	}
+}
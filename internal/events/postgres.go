@@ -0,0 +1,129 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// Postgres refuses NOTIFY payloads from 8000 bytes on
+	MaxPayloadSize = 7999
+
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+	pingInterval = time.Second * 90
+)
+
+var ErrPayloadTooLarge = errors.New("event too large for NOTIFY")
+
+// Postgres is a bus over LISTEN/NOTIFY on one channel. Events are published
+// with pg_notify through the application's *sql.DB and received on a
+// dedicated lib/pq listener connection, which reconnects on its own.
+// Events sent while it is reconnecting are lost, NOTIFY doesn't keep them.
+// IDs come from a database sequence shared by every instance.
+type Postgres struct {
+	db       *sql.DB
+	channel  string
+	sequence string
+	listener *pq.Listener
+	handlers handlers
+	done     chan struct{}
+}
+
+// NewPostgres starts listening on channel with a connection to dbUrl, the
+// same connection string the *sql.DB was opened with. Event IDs are taken
+// from sequence, which must exist.
+func NewPostgres(db *sql.DB, dbUrl, channel, sequence string) (*Postgres, error) {
+	listener := pq.NewListener(dbUrl, minReconnect, maxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Event bus listener: %v", err)
+		}
+	})
+
+	err := listener.Listen(channel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	p := &Postgres{
+		db:       db,
+		channel:  channel,
+		sequence: sequence,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+
+	go p.listen()
+
+	return p, nil
+}
+
+// Publish numbers event from the sequence and notifies it. Two events
+// published at the same time from different instances may be received in
+// the other order than their IDs, never with the same ID.
+func (p *Postgres) Publish(ctx context.Context, event Event) error {
+	err := p.db.QueryRowContext(ctx, "SELECT nextval($1::regclass)", p.sequence).Scan(&event.ID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(payload) > MaxPayloadSize {
+		return ErrPayloadTooLarge
+	}
+
+	_, err = p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", p.channel, string(payload))
+	return err
+}
+
+func (p *Postgres) Subscribe(handler Handler) func() {
+	return p.handlers.add(handler)
+}
+
+func (p *Postgres) Close() error {
+	close(p.done)
+	return p.listener.Close()
+}
+
+func (p *Postgres) listen() {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ping.C:
+			// Notices dead connections when the channel is quiet
+			go p.listener.Ping()
+		case notification, ok := <-p.listener.NotificationChannel():
+			if !ok {
+				return
+			}
+			// nil after a reconnection, anything sent meanwhile is gone
+			if notification == nil {
+				log.Printf("Event bus reconnected, events may have been missed")
+				continue
+			}
+
+			var event Event
+			err := json.Unmarshal([]byte(notification.Extra), &event)
+			if err != nil {
+				log.Printf("Event bus: invalid event: %v", err)
+				continue
+			}
+
+			p.handlers.dispatch(event)
+		}
+	}
+}
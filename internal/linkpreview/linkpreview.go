@@ -0,0 +1,214 @@
+// Package linkpreview fetches web pages and extracts the OpenGraph and
+// Twitter card metadata used to render preview cards.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// MaxBodySize is how much of a page is read. Metadata lives in <head>,
+	// so anything past it is not worth downloading.
+	MaxBodySize      = 512 << 10
+	MaxTitleLength   = 200
+	MaxTextLength    = 500
+	MaxURLLength     = 2048
+	userAgent        = "ChirpyBot/1.0 (+link previews)"
+	htmlContentType  = "text/html"
+	xhtmlContentType = "application/xhtml+xml"
+)
+
+var ErrNotHTML = errors.New("not an HTML page")
+
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// NormalizeURL turns a link found in a chirp into an absolute http(s) URL,
+// adding a scheme to bare domains. It returns false for anything else.
+func NormalizeURL(link string) (string, bool) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return "", false
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", false
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+
+	normalized := parsed.String()
+	if len(normalized) > MaxURLLength {
+		return "", false
+	}
+
+	return normalized, true
+}
+
+// Fetch downloads pageURL with client and extracts its preview. The client
+// is responsible for timeouts and for refusing internal addresses, see the
+// safehttp package.
+func Fetch(ctx context.Context, client *http.Client, pageURL string) (Preview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("unexpected status %s", res.Status)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != htmlContentType && mediaType != xhtmlContentType {
+		return Preview{}, ErrNotHTML
+	}
+
+	// Relative image URLs are resolved against the final URL after redirects
+	preview, err := Parse(io.LimitReader(res.Body, MaxBodySize), res.Request.URL)
+	if err != nil {
+		return Preview{}, err
+	}
+	preview.URL = pageURL
+
+	return preview, nil
+}
+
+// Parse extracts the preview metadata of an HTML document. OpenGraph tags
+// win over Twitter card tags, which win over <title> and the plain
+// description meta tag.
+func Parse(r io.Reader, base *url.URL) (Preview, error) {
+	meta := make(map[string]string)
+	title := ""
+
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+loop:
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				break loop
+			}
+			return Preview{}, tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "meta":
+				key, content := "", ""
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(attr.Val))
+					case "content":
+						content = attr.Val
+					}
+				}
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = content
+				}
+			case "title":
+				inTitle = title == ""
+			case "body":
+				// Metadata belongs in <head>, stop before the page content
+				break loop
+			}
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			if name, _ := tokenizer.TagName(); string(name) == "title" {
+				inTitle = false
+			}
+		}
+	}
+
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := strings.TrimSpace(meta[key]); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	preview := Preview{
+		Title:       clean(first("og:title", "twitter:title"), MaxTitleLength),
+		Description: clean(first("og:description", "twitter:description", "description"), MaxTextLength),
+		SiteName:    clean(first("og:site_name"), MaxTitleLength),
+		ImageURL:    resolveImage(base, first("og:image", "og:image:url", "twitter:image", "twitter:image:src")),
+	}
+	if preview.Title == "" {
+		preview.Title = clean(title, MaxTitleLength)
+	}
+
+	return preview, nil
+}
+
+// resolveImage makes an image reference absolute and drops anything that is
+// not a plain http(s) URL.
+func resolveImage(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		parsed = base.ResolveReference(parsed)
+	}
+
+	normalized, ok := NormalizeURL(parsed.String())
+	if !ok {
+		return ""
+	}
+
+	return normalized
+}
+
+// clean collapses whitespace and truncates s to at most limit bytes without
+// splitting a UTF-8 sequence.
+func clean(s string, limit int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if len(s) <= limit {
+		return s
+	}
+
+	s = s[:limit]
+	for !utf8.ValidString(s) { // only the cut sequence can be invalid
+		s = s[:len(s)-1]
+	}
+
+	return strings.TrimSpace(s) + "…"
+}
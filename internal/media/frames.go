@@ -0,0 +1,58 @@
+package media
+
+// gifFrames counts the frames of a GIF by walking its blocks, without
+// decoding any of them. It returns -1 when data isn't a GIF it can walk to
+// the trailer, which must be rejected: the frames can't be bounded then.
+func gifFrames(data []byte) int {
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return -1
+	}
+	pos := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1)
+	}
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension: label, then data sub-blocks
+			pos = skipSubBlocks(data, pos+2)
+		case 0x2C: // image descriptor, then LZW code size and data sub-blocks
+			if pos+10 > len(data) {
+				return -1
+			}
+			frames++
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos = skipSubBlocks(data, pos+1)
+		case 0x3B: // trailer
+			return frames
+		default:
+			return -1
+		}
+		if pos < 0 {
+			return -1
+		}
+	}
+
+	return -1
+}
+
+// skipSubBlocks returns the position after the sub-blocks starting at pos,
+// or -1 when they run past the end of data.
+func skipSubBlocks(data []byte, pos int) int {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos
+		}
+		pos += size
+	}
+
+	return -1
+}
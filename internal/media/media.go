@@ -0,0 +1,168 @@
+// Package media validates and normalizes uploaded images.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	MaxUploadSize = 10 << 20
+	// MaxPixels guards against decompression bombs: small files that decode
+	// into huge images.
+	MaxPixels = 40_000_000
+	// MaxGIFPixels bounds the pixels of all the frames of a GIF together,
+	// each one is decoded at the size of the canvas in the worst case.
+	MaxGIFPixels   = 200_000_000
+	MaxGIFFrames   = 500
+	ThumbnailSize  = 320
+	jpegQuality    = 90
+	thumbnailImage = "image/jpeg"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type, expected a JPEG, PNG, GIF or WebP image")
+	ErrTooLarge        = errors.New("image dimensions are too large")
+	ErrTooManyFrames   = errors.New("animation has too many frames")
+)
+
+// Image is an upload after processing. Data and Thumbnail were re-encoded
+// from the decoded pixels, so no metadata (EXIF, GPS, comments...) of the
+// original file survives.
+type Image struct {
+	MimeType      string
+	Width         int
+	Height        int
+	Data          []byte
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// Sniff detects the type of data from its content, ignoring whatever the
+// client claimed, and returns ErrUnsupportedType for anything but images we
+// can process.
+func Sniff(data []byte) (string, error) {
+	mimeType := http.DetectContentType(data)
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return mimeType, nil
+	default:
+		return "", ErrUnsupportedType
+	}
+}
+
+// Process sniffs, decodes and re-encodes an uploaded image and renders its
+// thumbnail. JPEGs are rotated according to their EXIF orientation before it
+// is dropped. WebP images are stored as PNG since there is no WebP encoder
+// in the standard library or x/image.
+func Process(data []byte) (Image, error) {
+	mimeType, err := Sniff(data)
+	if err != nil {
+		return Image{}, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	var img image.Image
+	var buf bytes.Buffer
+
+	switch mimeType {
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		img = applyOrientation(img, jpegOrientation(data))
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	case "image/png", "image/webp":
+		if mimeType == "image/webp" {
+			img, err = webp.Decode(bytes.NewReader(data))
+		} else {
+			img, err = png.Decode(bytes.NewReader(data))
+		}
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		mimeType = "image/png"
+		err = png.Encode(&buf, img)
+	case "image/gif":
+		// Count the frames before decoding them, DecodeAll has no limit
+		frames := gifFrames(data)
+		if frames < 0 {
+			return Image{}, ErrUnsupportedType
+		}
+		if frames > MaxGIFFrames || frames*config.Width*config.Height > MaxGIFPixels {
+			return Image{}, ErrTooManyFrames
+		}
+
+		// Keep every frame so animations still play
+		var anim *gif.GIF
+		anim, err = gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(anim.Image) == 0 {
+			return Image{}, ErrUnsupportedType
+		}
+		img = anim.Image[0]
+		err = gif.EncodeAll(&buf, anim)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	thumbnail, err := Thumbnail(img, ThumbnailSize)
+	if err != nil {
+		return Image{}, err
+	}
+
+	bounds := img.Bounds()
+	return Image{
+		MimeType:      mimeType,
+		Width:         bounds.Dx(),
+		Height:        bounds.Dy(),
+		Data:          buf.Bytes(),
+		Thumbnail:     thumbnail,
+		ThumbnailType: thumbnailImage,
+	}, nil
+}
+
+// Thumbnail scales img down to fit in a size x size box, keeping its aspect
+// ratio, and encodes it as a JPEG. Transparent areas become white.
+func Thumbnail(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > size || height > size {
+		if width >= height {
+			height = max(1, height*size/width)
+			width = size
+		} else {
+			width = max(1, width*size/height)
+			height = size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,101 @@
+package moderation
+
+import "strings"
+
+// Action is what a filter wants done with a chirp. Actions are ordered by
+// severity so the pipeline can keep the strictest one.
+type Action int
+
+const (
+	Allow Action = iota
+	Mask
+	Review
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Mask:
+		return "mask"
+	case Review:
+		return "review"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+const MaskText = "****"
+
+type Decision struct {
+	Action Action
+	Body   string
+	Filter string
+	Reason string
+}
+
+type Filter interface {
+	Name() string
+	Check(body string) Decision
+}
+
+// Pipeline runs filters in order. Masks are applied to the body seen by the
+// following filters, a rejection stops the chain straight away and a review
+// request is carried to the end unless something rejects the chirp first.
+type Pipeline struct {
+	filters []Filter
+}
+
+func NewPipeline(filters ...Filter) *Pipeline {
+	return &Pipeline{filters: filters}
+}
+
+func (p *Pipeline) Run(body string) Decision {
+	result := Decision{Action: Allow, Body: body}
+
+	for _, filter := range p.filters {
+		decision := filter.Check(result.Body)
+
+		switch decision.Action {
+		case Reject:
+			decision.Filter = filter.Name()
+			decision.Body = result.Body
+			return decision
+		case Review:
+			if result.Action < Review {
+				result.Action = Review
+				result.Filter = filter.Name()
+				result.Reason = decision.Reason
+			}
+		case Mask:
+			result.Body = decision.Body
+			if result.Action < Mask {
+				result.Action = Mask
+				result.Filter = filter.Name()
+				result.Reason = decision.Reason
+			}
+		}
+	}
+
+	return result
+}
+
+// maskSpans replaces the given byte ranges of body with MaskText, leaving
+// everything in between untouched. Spans must be sorted and not overlap.
+func maskSpans(body string, spans [][2]int) string {
+	if len(spans) == 0 {
+		return body
+	}
+
+	var masked strings.Builder
+	last := 0
+	for _, span := range spans {
+		masked.WriteString(body[last:span[0]])
+		masked.WriteString(MaskText)
+		last = span[1]
+	}
+	masked.WriteString(body[last:])
+
+	return masked.String()
+}
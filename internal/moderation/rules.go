@@ -0,0 +1,116 @@
+package moderation
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type RegexRule struct {
+	Pattern *regexp.Regexp
+	Action  Action
+	Reason  string
+}
+
+// RegexFilter applies its rules in order. Masking rules rewrite every match,
+// the first rule asking for review or rejection decides the outcome.
+type RegexFilter struct {
+	rules []RegexRule
+}
+
+func NewRegexFilter(rules ...RegexRule) *RegexFilter {
+	return &RegexFilter{rules: rules}
+}
+
+func (rf *RegexFilter) Name() string {
+	return "regex"
+}
+
+func (rf *RegexFilter) Check(body string) Decision {
+	result := Decision{Action: Allow, Body: body}
+
+	for _, rule := range rf.rules {
+		matches := rule.Pattern.FindAllStringIndex(result.Body, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		if rule.Action != Mask {
+			return Decision{Action: rule.Action, Body: body, Reason: rule.Reason}
+		}
+
+		spans := make([][2]int, 0, len(matches))
+		for _, match := range matches {
+			spans = append(spans, [2]int{match[0], match[1]})
+		}
+		result = Decision{Action: Mask, Body: maskSpans(result.Body, spans), Reason: rule.Reason}
+	}
+
+	return result
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://)?(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}(?::\d+)?(?:/[^\s]*)?`)
+
+// FindLinks returns the URLs and bare domains mentioned in body.
+func FindLinks(body string) []string {
+	return linkPattern.FindAllString(body, -1)
+}
+
+// LinkBlocklist rejects chirps linking to a blocked domain or any of its
+// subdomains.
+type LinkBlocklist struct {
+	action  Action
+	domains map[string]bool
+}
+
+func NewLinkBlocklist(action Action, domains ...string) *LinkBlocklist {
+	lb := &LinkBlocklist{action: action, domains: make(map[string]bool, len(domains))}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			lb.domains[domain] = true
+		}
+	}
+	return lb
+}
+
+func (lb *LinkBlocklist) Name() string {
+	return "link_blocklist"
+}
+
+func (lb *LinkBlocklist) Check(body string) Decision {
+	for _, link := range FindLinks(body) {
+		if lb.blocked(hostOf(link)) {
+			return Decision{Action: lb.action, Body: body, Reason: "blocked link"}
+		}
+	}
+
+	return Decision{Action: Allow, Body: body}
+}
+
+func (lb *LinkBlocklist) blocked(host string) bool {
+	for host != "" {
+		if lb.domains[host] {
+			return true
+		}
+		_, parent, found := strings.Cut(host, ".")
+		if !found {
+			return false
+		}
+		host = parent
+	}
+	return false
+}
+
+func hostOf(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,102 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Alb3G/chirpy/internal/entities"
+)
+
+// SpamScorer adds up a few cheap heuristics. Chirps scoring at least
+// ReviewScore are queued for review and those reaching RejectScore are
+// rejected outright.
+type SpamScorer struct {
+	ReviewScore int
+	RejectScore int
+}
+
+func NewSpamScorer() *SpamScorer {
+	return &SpamScorer{ReviewScore: 3, RejectScore: 5}
+}
+
+func (ss *SpamScorer) Name() string {
+	return "spam_score"
+}
+
+func (ss *SpamScorer) Check(body string) Decision {
+	score := SpamScore(body)
+
+	switch {
+	case score >= ss.RejectScore:
+		return Decision{Action: Reject, Body: body, Reason: "looks like spam"}
+	case score >= ss.ReviewScore:
+		return Decision{Action: Review, Body: body, Reason: "possible spam"}
+	default:
+		return Decision{Action: Allow, Body: body}
+	}
+}
+
+func SpamScore(body string) int {
+	score := 0
+
+	letters, upper := 0, 0
+	for _, r := range body {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= 20 && upper*10 >= letters*7 {
+		score++
+	}
+
+	if links := len(FindLinks(body)); links >= 3 {
+		score += 2
+	} else if links == 2 {
+		score++
+	}
+
+	if len(entities.ExtractHashtags(body)) >= 5 {
+		score++
+	}
+	if len(entities.ExtractMentions(body)) >= 5 {
+		score++
+	}
+
+	if longestRun(body) >= 8 {
+		score++
+	}
+
+	words := strings.Fields(strings.ToLower(body))
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word]++
+		if counts[word] == 4 {
+			score++
+			break
+		}
+	}
+
+	return score
+}
+
+// longestRun returns the length of the longest run of one repeated rune, as
+// in "freeeeeeee".
+func longestRun(body string) int {
+	longest, current := 0, 0
+	var prev rune = -1
+
+	for _, r := range body {
+		if r == prev {
+			current++
+		} else {
+			current = 1
+			prev = r
+		}
+		longest = max(longest, current)
+	}
+
+	return longest
+}
@@ -0,0 +1,121 @@
+package moderation
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WordList matches whole words regardless of case, accents or compatibility
+// forms, so "Kérfuffle!" is caught by "kerfuffle" while the punctuation and
+// spacing around it are kept. It is safe to edit while chirps are checked.
+type WordList struct {
+	mu     sync.RWMutex
+	action Action
+	words  map[string]bool
+}
+
+func NewWordList(action Action, words ...string) *WordList {
+	wl := &WordList{action: action}
+	wl.Replace(words)
+	return wl
+}
+
+func (wl *WordList) Name() string {
+	return "word_list"
+}
+
+// Words returns the normalized words in the list, sorted.
+func (wl *WordList) Words() []string {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	words := make([]string, 0, len(wl.words))
+	for word := range wl.words {
+		words = append(words, word)
+	}
+	slices.Sort(words)
+
+	return words
+}
+
+func (wl *WordList) Replace(words []string) {
+	normalized := make(map[string]bool, len(words))
+	for _, word := range words {
+		if word = NormalizeWord(word); word != "" {
+			normalized[word] = true
+		}
+	}
+
+	wl.mu.Lock()
+	defer wl.mu.Unlock()
+
+	wl.words = normalized
+}
+
+func (wl *WordList) Check(body string) Decision {
+	wl.mu.RLock()
+	defer wl.mu.RUnlock()
+
+	spans := make([][2]int, 0)
+	for _, span := range wordSpans(body) {
+		if wl.words[NormalizeWord(body[span[0]:span[1]])] {
+			spans = append(spans, span)
+		}
+	}
+
+	if len(spans) == 0 {
+		return Decision{Action: Allow, Body: body}
+	}
+
+	if wl.action != Mask {
+		return Decision{Action: wl.action, Body: body, Reason: "banned word"}
+	}
+
+	return Decision{Action: Mask, Body: maskSpans(body, spans), Reason: "banned word"}
+}
+
+// NormalizeWord folds case, compatibility forms and diacritics so that
+// visually similar spellings of a word compare equal.
+func NormalizeWord(word string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, strings.TrimSpace(word))
+	if err != nil {
+		stripped = word
+	}
+
+	return cases.Fold().String(stripped)
+}
+
+// wordSpans returns the byte ranges of the runs of letters, digits and marks
+// in body. Everything else, punctuation included, separates words.
+func wordSpans(body string) [][2]int {
+	spans := make([][2]int, 0)
+	start := -1
+
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+
+		if inWord && start < 0 {
+			start = i
+		} else if !inWord && start >= 0 {
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+		i += size
+	}
+
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(body)})
+	}
+
+	return spans
+}
@@ -0,0 +1,133 @@
+// Package pubsub is an in-process publish/subscribe hub for realtime
+// endpoints. It keeps the most recent events in a ring buffer so that
+// subscribers reconnecting with the ID of the last event they saw can catch
+// up on what they missed. IDs are given by the publisher, so hubs fed the
+// same events with the same IDs can resume each other's subscribers.
+package pubsub
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSlowSubscriber is reported by subscriptions the hub dropped because they
+// didn't keep up with the events published.
+var ErrSlowSubscriber = errors.New("subscriber too slow, events dropped")
+
+type Event struct {
+	ID      uint64
+	Type    string
+	Payload any
+}
+
+type Hub struct {
+	mu        sync.Mutex
+	ring      []Event
+	start     int
+	count     int
+	subBuffer int
+	subs      map[*Subscription]struct{}
+}
+
+// NewHub creates a hub remembering the last bufferSize events, with
+// subscriptions buffering up to subBuffer undelivered events before being
+// dropped.
+func NewHub(bufferSize, subBuffer int) *Hub {
+	return &Hub{
+		ring:      make([]Event, bufferSize),
+		subBuffer: subBuffer,
+		subs:      make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records an event with id and hands it to every subscription. IDs
+// should increase from one event to the next. It never blocks: a
+// subscription whose buffer is full is closed with ErrSlowSubscriber
+// instead.
+func (h *Hub) Publish(id uint64, eventType string, payload any) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	event := Event{ID: id, Type: eventType, Payload: payload}
+
+	if len(h.ring) > 0 {
+		h.ring[(h.start+h.count)%len(h.ring)] = event
+		if h.count < len(h.ring) {
+			h.count++
+		} else {
+			h.start = (h.start + 1) % len(h.ring)
+		}
+	}
+
+	for sub := range h.subs {
+		select {
+		case sub.c <- event:
+		default:
+			sub.err = ErrSlowSubscriber
+			h.remove(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a new subscription. With resume set, the buffered
+// events after lastID are returned so the caller can send them before
+// reading from the subscription, without missing or repeating any.
+func (h *Hub) Subscribe(lastID uint64, resume bool) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var missed []Event
+	if resume {
+		for i := 0; i < h.count; i++ {
+			event := h.ring[(h.start+i)%len(h.ring)]
+			if event.ID > lastID {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	sub := &Subscription{
+		hub: h,
+		c:   make(chan Event, h.subBuffer),
+	}
+	h.subs[sub] = struct{}{}
+
+	return sub, missed
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
+
+type Subscription struct {
+	hub *Hub
+	c   chan Event
+	err error
+}
+
+// Events is closed when the subscription ends, either through Close or
+// because the hub dropped it.
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Err reports why the hub closed the subscription, nil if it didn't.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s)
+}
@@ -0,0 +1,119 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/entities"
+)
+
+const dateLayout = "2006-01-02"
+
+// Query is a parsed search string. Text keeps whatever was not recognised as
+// an operator, including "quoted phrases" and -exclusions, so it can be handed
+// to websearch_to_tsquery as is.
+type Query struct {
+	Text     string
+	From     string
+	Since    *time.Time
+	Until    *time.Time
+	Hashtags []string
+}
+
+// Parse understands the operators from:handle, since:YYYY-MM-DD,
+// until:YYYY-MM-DD (inclusive) and #hashtag on top of free text.
+func Parse(raw string) (Query, error) {
+	var query Query
+	text := make([]string, 0)
+
+	for _, token := range tokenize(raw) {
+		if strings.HasPrefix(token, "\"") {
+			text = append(text, token)
+			continue
+		}
+
+		operator, value, found := strings.Cut(token, ":")
+		switch {
+		case found && strings.EqualFold(operator, "from"):
+			handle := entities.NormalizeHandle(value)
+			if !entities.ValidHandle(handle) {
+				return Query{}, errors.New("invalid from: handle")
+			}
+			query.From = handle
+		case found && strings.EqualFold(operator, "since"):
+			since, err := time.Parse(dateLayout, value)
+			if err != nil {
+				return Query{}, errors.New("since: must be a date like 2006-01-02")
+			}
+			query.Since = &since
+		case found && strings.EqualFold(operator, "until"):
+			until, err := time.Parse(dateLayout, value)
+			if err != nil {
+				return Query{}, errors.New("until: must be a date like 2006-01-02")
+			}
+			until = until.Add(time.Hour * 24)
+			query.Until = &until
+		case strings.HasPrefix(token, "#"):
+			tag := entities.NormalizeTag(token)
+			if tag == "" {
+				continue
+			}
+			query.Hashtags = append(query.Hashtags, tag)
+		default:
+			text = append(text, token)
+		}
+	}
+
+	query.Text = strings.Join(text, " ")
+
+	if query.IsEmpty() {
+		return Query{}, errors.New("empty search query")
+	}
+
+	return query, nil
+}
+
+func (q Query) IsEmpty() bool {
+	return q.Text == "" && q.From == "" && q.Since == nil && q.Until == nil && len(q.Hashtags) == 0
+}
+
+// tokenize splits on whitespace but keeps "quoted phrases" together,
+// quotes included.
+func tokenize(raw string) []string {
+	tokens := make([]string, 0)
+	var current strings.Builder
+	inQuotes := false
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			if inQuotes {
+				current.WriteRune(r)
+				flush()
+			} else {
+				flush()
+				current.WriteRune(r)
+			}
+			inQuotes = !inQuotes
+		case !inQuotes && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	if inQuotes {
+		current.WriteRune('"')
+	}
+	flush()
+
+	return tokens
+}
@@ -0,0 +1,136 @@
+// Package subscription is the lifecycle of paid subscriptions, kept apart
+// from where they are stored. Events are named after the ones of Polka,
+// which may deliver them more than once and in any order.
+package subscription
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/entitlements"
+)
+
+const (
+	StatusActive = "active"
+	// Ended early by a downgrade
+	StatusCanceled = "canceled"
+	// Ran out at the end of a period that wasn't renewed
+	StatusExpired = "expired"
+
+	Upgraded   = "user.upgraded"
+	Downgraded = "user.downgraded"
+	Renewed    = "subscription.renewed"
+	Canceled   = "subscription.canceled"
+
+	// Used when an event doesn't say when the paid period ends
+	Period = time.Hour * 24 * 30
+)
+
+// Lifetime is the period end of members from before subscriptions, who paid
+// once for Red forever. Periods only move forward, so events never cut it
+// short, only a downgrade ends it.
+var Lifetime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+var (
+	ErrUnknownPlan    = errors.New("unknown plan")
+	ErrNoSubscription = errors.New("user has no subscription")
+	ErrUnknownEvent   = errors.New("unknown subscription event")
+)
+
+type Subscription struct {
+	Plan              string
+	Status            string
+	CurrentPeriodEnd  time.Time
+	CancelAtPeriodEnd bool
+	CanceledAt        *time.Time
+}
+
+type Event struct {
+	Type string
+	// Upgrades only, the plan subscribed to
+	Plan string
+	// End of the period paid for, optional
+	CurrentPeriodEnd *time.Time
+}
+
+// Apply returns sub after event happened at now, sub being nil for users who
+// never subscribed. It returns nil when the event changes nothing, like a
+// cancellation of a subscription that already ended. Periods only move
+// forward, so a late renewal can't cut short a newer one.
+func Apply(sub *Subscription, event Event, now time.Time) (*Subscription, error) {
+	switch event.Type {
+	case Upgraded:
+		// Free isn't something to subscribe to
+		if event.Plan == entitlements.PlanFree || !slices.Contains(entitlements.Plans(), event.Plan) {
+			return nil, ErrUnknownPlan
+		}
+
+		periodEnd := now.Add(Period)
+		if event.CurrentPeriodEnd != nil {
+			periodEnd = *event.CurrentPeriodEnd
+		}
+		if sub != nil && sub.Status == StatusActive && sub.Plan == event.Plan && sub.CurrentPeriodEnd.After(periodEnd) {
+			periodEnd = sub.CurrentPeriodEnd
+		}
+
+		return &Subscription{
+			Plan:             event.Plan,
+			Status:           StatusActive,
+			CurrentPeriodEnd: periodEnd,
+		}, nil
+	case Renewed:
+		if sub == nil {
+			return nil, ErrNoSubscription
+		}
+
+		// Renewals extend from the current end, or from now if it lapsed
+		periodEnd := sub.CurrentPeriodEnd
+		if event.CurrentPeriodEnd != nil {
+			periodEnd = latest(periodEnd, *event.CurrentPeriodEnd)
+		} else {
+			periodEnd = latest(periodEnd, now).Add(Period)
+		}
+
+		// Paying again undoes a cancellation
+		return &Subscription{
+			Plan:             sub.Plan,
+			Status:           StatusActive,
+			CurrentPeriodEnd: periodEnd,
+		}, nil
+	case Canceled:
+		// Members keep their perks until the end of the period paid for
+		if sub == nil || sub.Status != StatusActive || sub.CancelAtPeriodEnd {
+			return nil, nil
+		}
+
+		canceled := *sub
+		canceled.CancelAtPeriodEnd = true
+		canceled.CanceledAt = &now
+		return &canceled, nil
+	case Downgraded:
+		if sub == nil || sub.Status != StatusActive {
+			return nil, nil
+		}
+
+		ended := *sub
+		ended.Status = StatusCanceled
+		if ended.CurrentPeriodEnd.After(now) {
+			ended.CurrentPeriodEnd = now
+		}
+		if ended.CanceledAt == nil {
+			ended.CanceledAt = &now
+		}
+		return &ended, nil
+	default:
+		return nil, ErrUnknownEvent
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+
+	return b
+}
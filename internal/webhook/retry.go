@@ -0,0 +1,22 @@
+package webhook
+
+import "time"
+
+// Backoff returns how long to wait before retrying a delivery that has failed
+// attempts times: base after the first failure, doubling with every failure
+// after that, and never more than maxDelay.
+func Backoff(attempts int, base, maxDelay time.Duration) time.Duration {
+	if attempts < 1 {
+		return base
+	}
+
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	return min(delay, maxDelay)
+}
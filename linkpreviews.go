@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/linkpreview"
+	"github.com/Alb3G/chirpy/internal/moderation"
+)
+
+const (
+	LINK_PREVIEW_INTERVAL = time.Second * 30
+	// Only links in chirps this recent get (re)fetched.
+	LINK_PREVIEW_WINDOW = DAY
+	LINK_PREVIEW_TTL    = DAY * 7
+	// Failed fetches are retried sooner than good previews are refreshed.
+	LINK_PREVIEW_RETRY = time.Hour
+	LINK_PREVIEW_BATCH = 20
+)
+
+// chirpPreviewURL returns the link of body that gets a preview card: the
+// first one.
+func chirpPreviewURL(body string) sql.NullString {
+	for _, link := range moderation.FindLinks(body) {
+		if normalized, ok := linkpreview.NormalizeURL(link); ok {
+			return sql.NullString{String: normalized, Valid: true}
+		}
+	}
+
+	return sql.NullString{}
+}
+
+func toLinkPreview(dbPreview database.LinkPreview) *LinkPreview {
+	return &LinkPreview{
+		URL:         dbPreview.Url,
+		Title:       dbPreview.Title,
+		Description: dbPreview.Description,
+		ImageURL:    dbPreview.ImageUrl,
+		SiteName:    dbPreview.SiteName,
+	}
+}
+
+// fetchPendingPreviews fetches the links of recent chirps that have no
+// preview yet, or a stale one, and caches the result by URL. Failures are
+// cached too so a dead link isn't fetched again on every run.
+func (ac *apiConfig) fetchPendingPreviews(ctx context.Context) error {
+	now := time.Now().UTC()
+	urls, err := ac.Queries.GetPendingPreviewUrls(ctx, database.GetPendingPreviewUrlsParams{
+		Since:       now.Add(-LINK_PREVIEW_WINDOW),
+		StaleBefore: now.Add(-LINK_PREVIEW_TTL),
+		RetryBefore: now.Add(-LINK_PREVIEW_RETRY),
+		MaxResults:  LINK_PREVIEW_BATCH,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		preview, fetchErr := linkpreview.Fetch(ctx, ac.PreviewClient, url)
+		if fetchErr != nil {
+			log.Printf("Error fetching link preview for %s: %v", url, fetchErr)
+		}
+
+		err = ac.Queries.UpsertLinkPreview(ctx, database.UpsertLinkPreviewParams{
+			Url:         url,
+			Ok:          fetchErr == nil && preview.Title != "",
+			Title:       preview.Title,
+			Description: preview.Description,
+			ImageUrl:    preview.ImageURL,
+			SiteName:    preview.SiteName,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runLinkPreviewJob fetches pending link previews every
+// LINK_PREVIEW_INTERVAL until ctx is done.
+func (ac *apiConfig) runLinkPreviewJob(ctx context.Context) {
+	ticker := time.NewTicker(LINK_PREVIEW_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := ac.fetchPendingPreviews(ctx); err != nil {
+			log.Printf("Error fetching link previews: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	MAX_LIST_MEMBERS            = 500
+	MAX_LIST_NAME_LENGTH        = 50
+	MAX_LIST_DESCRIPTION_LENGTH = 160
+)
+
+func toList(dbList database.List) List {
+	return List{
+		ID:          dbList.ID,
+		CreatedAt:   dbList.CreatedAt,
+		UpdatedAt:   dbList.UpdatedAt,
+		OwnerID:     dbList.OwnerID,
+		Name:        dbList.Name,
+		Description: dbList.Description,
+		IsPrivate:   dbList.IsPrivate,
+	}
+}
+
+func decodeList(w http.ResponseWriter, r *http.Request) (ListRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData ListRequest
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return ListRequest{}, false
+	}
+
+	reqData.Name = strings.TrimSpace(reqData.Name)
+	reqData.Description = strings.TrimSpace(reqData.Description)
+
+	if reqData.Name == "" || utf8.RuneCountInString(reqData.Name) > MAX_LIST_NAME_LENGTH {
+		respondWithError(w, 400, fmt.Sprintf("List names must be 1 to %d characters long", MAX_LIST_NAME_LENGTH))
+		return ListRequest{}, false
+	}
+	if utf8.RuneCountInString(reqData.Description) > MAX_LIST_DESCRIPTION_LENGTH {
+		respondWithError(w, 400, fmt.Sprintf("List descriptions can't be longer than %d characters", MAX_LIST_DESCRIPTION_LENGTH))
+		return ListRequest{}, false
+	}
+
+	return reqData, true
+}
+
+// listTarget loads the {listID} of the request as seen by viewer. Private
+// lists of other users answer 404, as if they didn't exist.
+func (ac *apiConfig) listTarget(w http.ResponseWriter, r *http.Request, viewer uuid.NullUUID) (database.List, bool) {
+	listId, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid list ID format")
+		return database.List{}, false
+	}
+
+	dbList, err := ac.Queries.GetListById(r.Context(), listId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "List not found")
+		return database.List{}, false
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return database.List{}, false
+	}
+
+	if dbList.IsPrivate && (!viewer.Valid || viewer.UUID != dbList.OwnerID) {
+		respondWithError(w, 404, "List not found")
+		return database.List{}, false
+	}
+
+	return dbList, true
+}
+
+// ownedListTarget is listTarget for the endpoints that change a list, which
+// only its owner may call.
+func (ac *apiConfig) ownedListTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.List, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return uuid.Nil, database.List{}, false
+	}
+
+	dbList, ok := ac.listTarget(w, r, uuid.NullUUID{UUID: userId, Valid: true})
+	if !ok {
+		return uuid.Nil, database.List{}, false
+	}
+
+	if dbList.OwnerID != userId {
+		respondWithError(w, 403, "You can only change your own lists")
+		return uuid.Nil, database.List{}, false
+	}
+
+	return userId, dbList, true
+}
+
+func (ac *apiConfig) createListHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	reqData, ok := decodeList(w, r)
+	if !ok {
+		return
+	}
+
+	dbList, err := ac.Queries.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:     userId,
+		Name:        reqData.Name,
+		Description: reqData.Description,
+		IsPrivate:   reqData.IsPrivate,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, toList(dbList))
+}
+
+// getListsHandler lists the lists of ?owner_id, or the caller's own lists
+// without it. Private lists only show up for their owner.
+func (ac *apiConfig) getListsHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := ac.viewerId(r)
+
+	ownerId := viewer.UUID
+	if s := r.URL.Query().Get("owner_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, 400, "Invalid owner ID format")
+			return
+		}
+		ownerId = id
+	} else if !viewer.Valid {
+		respondWithError(w, 401, "Missing owner_id or authentication")
+		return
+	}
+
+	dbLists, err := ac.Queries.GetListsByOwner(r.Context(), database.GetListsByOwnerParams{
+		OwnerID:        ownerId,
+		IncludePrivate: viewer.Valid && viewer.UUID == ownerId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	lists := make([]List, 0, len(dbLists))
+	for _, dbList := range dbLists {
+		lists = append(lists, toList(dbList))
+	}
+
+	respondWithJSON(w, 200, lists)
+}
+
+func (ac *apiConfig) getListHandler(w http.ResponseWriter, r *http.Request) {
+	dbList, ok := ac.listTarget(w, r, ac.viewerId(r))
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, 200, toList(dbList))
+}
+
+func (ac *apiConfig) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbList, ok := ac.ownedListTarget(w, r)
+	if !ok {
+		return
+	}
+
+	reqData, ok := decodeList(w, r)
+	if !ok {
+		return
+	}
+
+	dbList, err := ac.Queries.UpdateList(r.Context(), database.UpdateListParams{
+		Name:        reqData.Name,
+		Description: reqData.Description,
+		IsPrivate:   reqData.IsPrivate,
+		ID:          dbList.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, toList(dbList))
+}
+
+func (ac *apiConfig) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbList, ok := ac.ownedListTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.DeleteList(r.Context(), dbList.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (ac *apiConfig) getListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := ac.viewerId(r)
+	dbList, ok := ac.listTarget(w, r, viewer)
+	if !ok {
+		return
+	}
+
+	rows, err := ac.Queries.GetListMembers(r.Context(), dbList.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	blocked, err := ac.blockedUsers(r.Context(), viewer)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	users := make([]PublicUser, 0, len(rows))
+	for _, row := range rows {
+		if blocked[row.ID] {
+			continue
+		}
+		users = append(users, PublicUser{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName.String,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	err = ac.addBadges(r.Context(), users)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, users)
+}
+
+// addListMemberHandler adds user_id to a list. The list row is locked while
+// counting its members so concurrent adds can't go over MAX_LIST_MEMBERS.
+func (ac *apiConfig) addListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, dbList, ok := ac.ownedListTarget(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	member, err := ac.Queries.GetUserById(r.Context(), reqData.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	blocked, err := ac.Queries.BlockExists(r.Context(), database.BlockExistsParams{
+		UserA: userId,
+		UserB: member.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if blocked {
+		respondWithError(w, 403, "Can't add a user with a block between you")
+		return
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	_, err = qtx.LockList(r.Context(), dbList.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "List not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	count, err := qtx.CountListMembers(r.Context(), dbList.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if count >= MAX_LIST_MEMBERS {
+		respondWithError(w, 409, fmt.Sprintf("Lists can't have more than %d members", MAX_LIST_MEMBERS))
+		return
+	}
+
+	_, err = qtx.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: dbList.ID,
+		UserID: member.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (ac *apiConfig) removeListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	_, dbList, ok := ac.ownedListTarget(w, r)
+	if !ok {
+		return
+	}
+
+	memberId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID format")
+		return
+	}
+
+	removed, err := ac.Queries.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: dbList.ID,
+		UserID: memberId,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if removed == 0 {
+		respondWithError(w, 404, "User is not a member of this list")
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// getListChirpsHandler is the timeline of a list: chirps from its members,
+// newest first, with the usual visibility rules for the caller.
+func (ac *apiConfig) getListChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := ac.viewerId(r)
+	dbList, ok := ac.listTarget(w, r, viewer)
+	if !ok {
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetListChirpsParams{
+		ListID:     dbList.ID,
+		MaxResults: limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID.UUID, params.BeforeID.Valid = id, true
+	}
+
+	dbChirps, err := ac.Queries.GetListChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	dbChirps, nextCursor := paginateChirps(dbChirps, limit)
+
+	dbChirps, err = ac.filterChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	chirps, err := ac.renderChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+	})
+}
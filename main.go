@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/Alb3G/chirpy/internal/blobstore"
 	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/moderation"
+	"github.com/Alb3G/chirpy/internal/pubsub"
+	"github.com/Alb3G/chirpy/internal/safehttp"
 	"github.com/didip/tollbooth/v7"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -16,6 +27,8 @@ import (
 const (
 	FILE_PATH_ROOT = "."
 	PORT           = "8080"
+
+	SHUTDOWN_TIMEOUT = time.Second * 30
 )
 
 func main() {
@@ -24,7 +37,12 @@ func main() {
 	dbUrl := os.Getenv("DB_URL")
 	env := os.Getenv("ENV")
 	secret := os.Getenv("TOKEN_SECRET")
-	polka_key := os.Getenv("POLKA_KEY")
+	polkaSecrets := parseSecrets(os.Getenv("POLKA_WEBHOOK_SECRETS"))
+	blockedDomains := strings.Split(os.Getenv("MODERATION_BLOCKED_DOMAINS"), ",")
+
+	if len(polkaSecrets) == 0 {
+		log.Printf("POLKA_WEBHOOK_SECRETS is not set, Polka webhooks will be rejected")
+	}
 
 	db, err := sql.Open("postgres", dbUrl)
 	if err != nil {
@@ -33,34 +51,134 @@ func main() {
 
 	queries := database.New(db)
 
+	words, err := queries.GetBannedWords(context.Background())
+	if err != nil {
+		log.Fatalf("Error loading banned words: %v", err)
+	}
+	bannedWords := moderation.NewWordList(moderation.Mask, words...)
+
+	blobs, err := newBlobStore()
+	if err != nil {
+		log.Fatalf("Error setting up the media store: %v", err)
+	}
+
+	bus, err := newEventBus(db, dbUrl)
+	if err != nil {
+		log.Fatalf("Error setting up the event bus: %v", err)
+	}
+	defer bus.Close()
+
 	mux := http.NewServeMux()
 
 	apiCfg := &apiConfig{
-		Queries:     queries,
-		Env:         env,
-		TokenSecret: secret,
-		Key:         polka_key,
+		DB:           db,
+		Queries:      queries,
+		Env:          env,
+		TokenSecret:  secret,
+		PolkaSecrets: polkaSecrets,
+		Moderation:   newModerationPipeline(bannedWords, blockedDomains),
+		BannedWords:  bannedWords,
+		Blobs:        blobs,
+		// Chirp links are user input, never let them reach internal hosts
+		PreviewClient: safehttp.NewClient(safehttp.DefaultOptions()),
+		Events:        bus,
+		Stream:        pubsub.NewHub(STREAM_BUFFER_SIZE, STREAM_SUBSCRIBER_BUFFER),
+		ChirpLimiters: newChirpLimiters(),
+		// Endpoints are user input too, and redirects are never followed
+		WebhookClient: safehttp.NewClient(safehttp.Options{Timeout: DELIVERY_TIMEOUT}),
+		conns:         newConnTracker(),
 	}
 
+	bus.Subscribe(apiCfg.relayEvent)
+
+	go apiCfg.runTrendsJob(context.Background())
+	go apiCfg.runMediaGCJob(context.Background())
+	go apiCfg.runLinkPreviewJob(context.Background())
+	go apiCfg.runDraftScheduler(context.Background())
+	go apiCfg.runSubscriptionExpiryJob(context.Background())
+	go apiCfg.runWebhookDispatcher(context.Background())
+	go apiCfg.runBannedWordsReloadJob(context.Background())
+
 	limiter := tollbooth.NewLimiter(5, nil)
 
 	// GETs
 	mux.HandleFunc("GET /api/healthz", healthHandler)
 	mux.HandleFunc("GET /api/chirps", apiCfg.getChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpId}", apiCfg.getChirpById)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.getHashtagChirps)
+	mux.HandleFunc("GET /api/trends", apiCfg.getTrendsHandler)
+	mux.HandleFunc("GET /api/stream/chirps", apiCfg.streamChirpsHandler)
+	mux.HandleFunc("GET /api/ws", apiCfg.wsHandler)
+	mux.HandleFunc("GET /api/search/chirps", apiCfg.searchChirpsHandler)
+	mux.HandleFunc("GET /api/search/users", apiCfg.searchUsersHandler)
+	mux.HandleFunc("GET /api/users/autocomplete", apiCfg.autocompleteHandler)
+	mux.HandleFunc("GET /api/drafts", apiCfg.getDraftsHandler)
+	mux.HandleFunc("GET /api/drafts/{draftID}", apiCfg.getDraftHandler)
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.getBookmarksHandler)
+	mux.HandleFunc("GET /api/conversations", apiCfg.getConversationsHandler)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", apiCfg.getMessagesHandler)
+	mux.HandleFunc("GET /api/lists", apiCfg.getListsHandler)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.getListHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.getListMembersHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", apiCfg.getListChirpsHandler)
+	mux.HandleFunc("GET /api/notifications", apiCfg.getNotificationsHandler)
+	mux.HandleFunc("GET /api/notifications/unread_count", apiCfg.getUnreadCountHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.getMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apiCfg.getMediaThumbnailHandler)
+	mux.HandleFunc("GET /api/webhooks", apiCfg.getWebhookEndpointsHandler)
+	mux.HandleFunc("GET /api/webhooks/{endpointID}", apiCfg.getWebhookEndpointHandler)
+	mux.HandleFunc("GET /api/webhooks/{endpointID}/deliveries", apiCfg.getWebhookDeliveriesHandler)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.hitsHandler)
+	mux.HandleFunc("GET /admin/moderation/words", apiCfg.getBannedWordsHandler)
+	mux.HandleFunc("GET /admin/reports", apiCfg.getReportsHandler)
+	mux.HandleFunc("GET /admin/webhooks", apiCfg.getWebhookEventsHandler)
 	// POSTs
 	mux.HandleFunc("POST /api/users", apiCfg.usersHandler)
 	mux.HandleFunc("POST /api/chirps", apiCfg.chirpsHandler)
+	mux.HandleFunc("POST /api/media", apiCfg.uploadMediaHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apiCfg.votePollHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.bookmarkChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.pinChirpHandler)
+	mux.HandleFunc("POST /api/conversations", apiCfg.createConversationHandler)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/messages", apiCfg.sendMessageHandler)
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", apiCfg.markConversationReadHandler)
+	mux.HandleFunc("POST /api/lists", apiCfg.createListHandler)
+	mux.HandleFunc("POST /api/lists/{listID}/members", apiCfg.addListMemberHandler)
+	mux.HandleFunc("POST /api/drafts", apiCfg.createDraftHandler)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", apiCfg.publishDraftHandler)
+	mux.HandleFunc("POST /api/webhooks", apiCfg.createWebhookEndpointHandler)
+	mux.HandleFunc("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/retry", apiCfg.retryWebhookDeliveryHandler)
 	mux.Handle("POST /api/login", tollbooth.LimitFuncHandler(limiter, apiCfg.loginHandler))
 	mux.HandleFunc("POST /api/refresh", apiCfg.refreshTokenHandler)
 	mux.HandleFunc("POST /api/revoke", apiCfg.revokeTokenHandler)
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.upgradeUser)
+	mux.HandleFunc("POST /api/notifications/read", apiCfg.markNotificationsReadHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.blockUserHandler)
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.muteUserHandler)
+	mux.HandleFunc("POST /api/reports", apiCfg.createReportHandler)
+	mux.HandleFunc("POST /admin/reports/{reportID}/resolve", apiCfg.resolveReportHandler)
+	mux.HandleFunc("POST /admin/webhooks/{eventID}/replay", apiCfg.replayWebhookEventHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/suspend", apiCfg.suspendUserHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/shadowban", apiCfg.shadowBanHandler)
 	mux.HandleFunc("POST /admin/reset", apiCfg.resetHitsHandler)
 	// PUTs
 	mux.HandleFunc("PUT /api/users", apiCfg.updateUserHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.editChirpHandler)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.updateDraftHandler)
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.updateListHandler)
+	mux.HandleFunc("PUT /admin/moderation/words", apiCfg.replaceBannedWordsHandler)
 	// DELETEs
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirp)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.deleteDraftHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.unbookmarkChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.unpinChirpHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.deleteListHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.removeListMemberHandler)
+	mux.HandleFunc("DELETE /api/webhooks/{endpointID}", apiCfg.deleteWebhookEndpointHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.unblockUserHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.unmuteUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspend", apiCfg.unsuspendUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/shadowban", apiCfg.liftShadowBanHandler)
 
 	fileServer := http.FileServer(http.Dir(FILE_PATH_ROOT))
 	mux.Handle("/app/", http.StripPrefix("/app", fileServer))
@@ -70,7 +188,67 @@ func main() {
 		Handler: loggingMiddleware(mux),
 	}
 
-	fmt.Printf("Server running on port: 8080\n")
+	go func() {
+		fmt.Printf("Server running on port: 8080\n")
+
+		err := s.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	log.Printf("Shutting down, draining connections")
+
+	ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	// Streams and WebSockets first, Shutdown would wait on the streams forever
+	// and doesn't know about the WebSockets
+	if err := apiCfg.conns.drain(ctx); err != nil {
+		log.Printf("Error draining realtime connections: %v", err)
+	}
+
+	if err := s.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down the server: %v", err)
+	}
+}
+
+// newBlobStore picks the media backend from MEDIA_STORE: "s3" for any
+// S3-compatible service configured through the S3_* variables, or the local
+// filesystem under MEDIA_DIR by default. The fallback directory is outside
+// the working directory on purpose since /app/ serves all of it.
+func newBlobStore() (blobstore.BlobStore, error) {
+	if os.Getenv("MEDIA_STORE") == "s3" {
+		return blobstore.NewS3(blobstore.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+	}
+
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "chirpy-media")
+	}
+
+	return blobstore.NewLocal(dir)
+}
+
+// parseSecrets splits a comma separated list of secrets. Several can be
+// active at once while rotating them.
+func parseSecrets(s string) [][]byte {
+	var secrets [][]byte
+	for _, secret := range strings.Split(s, ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			secrets = append(secrets, []byte(secret))
+		}
+	}
 
-	log.Fatal(s.ListenAndServe())
+	return secrets
 }
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/blobstore"
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+const (
+	MEDIA_GC_INTERVAL = time.Hour
+	// Uploads not attached to a chirp or kept by a draft after this long are
+	// deleted, as are the media of deleted chirps.
+	MEDIA_ORPHAN_TTL = DAY
+	MEDIA_GC_BATCH   = 100
+)
+
+var errInvalidMedia = errors.New("media not found, not yours or already attached")
+
+func toMediaAttachment(upload database.MediaUpload) MediaAttachment {
+	return MediaAttachment{
+		ID:           upload.ID,
+		MimeType:     upload.MimeType,
+		Width:        int(upload.Width),
+		Height:       int(upload.Height),
+		URL:          "/api/media/" + upload.ID.String(),
+		ThumbnailURL: "/api/media/" + upload.ID.String() + "/thumbnail",
+	}
+}
+
+// uploadMediaHandler accepts a single image in the "file" field of a
+// multipart form. The stored image is re-encoded from its pixels, so it is
+// of a type we sniffed ourselves and carries no EXIF metadata.
+func (ac *apiConfig) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+1048576) // room for the form overhead
+
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, 400, "Expected an image in the file field of a multipart form")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if len(data) > media.MaxUploadSize {
+		respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Images can be at most %d MB", media.MaxUploadSize>>20))
+		return
+	}
+
+	img, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if errors.Is(err, media.ErrTooLarge) || errors.Is(err, media.ErrTooManyFrames) {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	id := uuid.New()
+	blobKey := "media/" + id.String() + "/original"
+	thumbnailKey := "media/" + id.String() + "/thumbnail"
+
+	err = ac.Blobs.Put(r.Context(), blobKey, img.Data, img.MimeType)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = ac.Blobs.Put(r.Context(), thumbnailKey, img.Thumbnail, img.ThumbnailType)
+	if err != nil {
+		ac.deleteBlobs(r.Context(), blobKey)
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	upload, err := ac.Queries.CreateMediaUpload(r.Context(), database.CreateMediaUploadParams{
+		ID:           id,
+		UserID:       userId,
+		MimeType:     img.MimeType,
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		SizeBytes:    int32(len(img.Data)),
+		BlobKey:      blobKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		ac.deleteBlobs(r.Context(), blobKey, thumbnailKey)
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, toMediaAttachment(upload))
+}
+
+func (ac *apiConfig) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	ac.serveMedia(w, r, false)
+}
+
+func (ac *apiConfig) getMediaThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	ac.serveMedia(w, r, true)
+}
+
+// serveMedia streams an upload from the blob store. Attached media are as
+// visible as their chirp and unattached ones only to the uploader, anything
+// else is a 404 like for chirps.
+func (ac *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaId, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid media ID format")
+		return
+	}
+
+	upload, err := ac.Queries.GetMediaUploadById(r.Context(), mediaId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Media not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	viewer := ac.viewerId(r)
+	visible := viewer.Valid && viewer.UUID == upload.UserID
+	if upload.ChirpID.Valid {
+		dbChirp, err := ac.Queries.GetChirpById(r.Context(), upload.ChirpID.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+		if err == nil {
+			visible, err = ac.canSeeChirp(r.Context(), viewer, dbChirp)
+			if err != nil {
+				respondWithError(w, 500, err.Error())
+				return
+			}
+		}
+	}
+	if !visible {
+		respondWithError(w, 404, "Media not found")
+		return
+	}
+
+	key, contentType := upload.BlobKey, upload.MimeType
+	if thumbnail {
+		key, contentType = upload.ThumbnailKey, "image/jpeg"
+	}
+
+	blob, err := ac.Blobs.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		respondWithError(w, 404, "Media not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.WriteHeader(200)
+	io.Copy(w, blob)
+}
+
+// attachChirpMedia links the given uploads of the chirp's author to it, in
+// order. Every upload can only ever belong to one chirp.
+func attachChirpMedia(ctx context.Context, q *database.Queries, chirp database.Chirp, mediaIds []uuid.UUID) error {
+	seen := make(map[uuid.UUID]bool, len(mediaIds))
+
+	for i, mediaId := range mediaIds {
+		if seen[mediaId] {
+			return fmt.Errorf("%w: %s is listed twice", errInvalidMedia, mediaId)
+		}
+		seen[mediaId] = true
+
+		attached, err := q.AttachMediaUpload(ctx, database.AttachMediaUploadParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+			ID:       mediaId,
+			UserID:   chirp.UserID,
+		})
+		if err != nil {
+			return err
+		}
+		if attached != 1 {
+			return fmt.Errorf("%w: %s", errInvalidMedia, mediaId)
+		}
+	}
+
+	return nil
+}
+
+func (ac *apiConfig) deleteBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := ac.Blobs.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+// collectOrphanedMedia deletes the uploads that were never attached to a
+// chirp, or whose chirp is gone, along with their blobs.
+func (ac *apiConfig) collectOrphanedMedia(ctx context.Context) error {
+	for {
+		uploads, err := ac.Queries.GetOrphanedMediaUploads(ctx, database.GetOrphanedMediaUploadsParams{
+			Before:     time.Now().UTC().Add(-MEDIA_ORPHAN_TTL),
+			MaxResults: MEDIA_GC_BATCH,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, upload := range uploads {
+			// Drop the blobs first: a failure leaves the row to retry later
+			// rather than blobs nothing points to.
+			err = ac.Blobs.Delete(ctx, upload.BlobKey)
+			if err == nil {
+				err = ac.Blobs.Delete(ctx, upload.ThumbnailKey)
+			}
+			if err != nil {
+				return err
+			}
+
+			err = ac.Queries.DeleteMediaUpload(ctx, upload.ID)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(uploads) < MEDIA_GC_BATCH {
+			return nil
+		}
+	}
+}
+
+// runMediaGCJob collects orphaned media every MEDIA_GC_INTERVAL until ctx is
+// done.
+func (ac *apiConfig) runMediaGCJob(ctx context.Context) {
+	ticker := time.NewTicker(MEDIA_GC_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		if err := ac.collectOrphanedMedia(ctx); err != nil {
+			log.Printf("Error collecting orphaned media: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
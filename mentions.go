@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entities"
+	"github.com/google/uuid"
+)
+
+var errMentionBlocked = errors.New("can't mention a user with a block between you")
+
+// saveChirpMentions resolves the @handles of a chirp to users and stores them
+// with their byte offsets. Unknown handles are left as plain text. Users that
+// were not already mentioned by a previous version of the chirp get notified.
+// Mentioning someone on the other side of a block fails with errMentionBlocked.
+func saveChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	previous, err := q.GetMentionedUserIds(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	err = q.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+
+	mentions := entities.ExtractMentions(chirp.Body)
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	handles := make([]string, 0, len(mentions))
+	for _, mention := range mentions {
+		handles = append(handles, mention.Handle)
+	}
+
+	users, err := q.GetUsersByHandles(ctx, handles)
+	if err != nil {
+		return err
+	}
+
+	userIds := make(map[string]uuid.UUID, len(users))
+	for _, user := range users {
+		userIds[user.Handle.String] = user.ID
+	}
+
+	// Mentions from shadow-banned users are still stored so the author sees
+	// them rendered, but nobody gets notified.
+	shadowBanned, err := q.GetShadowBannedUserIds(ctx, []uuid.UUID{chirp.UserID})
+	if err != nil {
+		return err
+	}
+
+	notified := make(map[uuid.UUID]bool, len(previous))
+	for _, userId := range previous {
+		notified[userId] = true
+	}
+
+	for _, mention := range mentions {
+		userId, ok := userIds[mention.Handle]
+		if !ok {
+			continue
+		}
+
+		blocked, err := q.BlockExists(ctx, database.BlockExistsParams{
+			UserA: chirp.UserID,
+			UserB: userId,
+		})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return fmt.Errorf("%w: @%s", errMentionBlocked, mention.Handle)
+		}
+
+		err = q.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      userId,
+			StartOffset: int32(mention.Start),
+			EndOffset:   int32(mention.End),
+		})
+		if err != nil {
+			return err
+		}
+
+		if notified[userId] || userId == chirp.UserID || len(shadowBanned) > 0 {
+			continue
+		}
+		notified[userId] = true
+
+		err = q.CreateNotification(ctx, database.CreateNotificationParams{
+			UserID:  userId,
+			ActorID: chirp.UserID,
+			Type:    NOTIFICATION_MENTION,
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
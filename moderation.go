@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const (
+	CHIRP_PUBLISHED      = "published"
+	CHIRP_PENDING_REVIEW = "pending_review"
+
+	// Events are best effort, instances that missed a change still catch
+	// up this often
+	BANNED_WORDS_RELOAD_INTERVAL = time.Minute * 5
+)
+
+// newModerationPipeline chains the filters every new chirp goes through, in
+// order: banned words, regex rules, blocked links and the spam scorer.
+func newModerationPipeline(bannedWords *moderation.WordList, blockedDomains []string) *moderation.Pipeline {
+	rules := moderation.NewRegexFilter(
+		moderation.RegexRule{
+			Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			Action:  moderation.Mask,
+			Reason:  "looks like a card number",
+		},
+	)
+
+	return moderation.NewPipeline(
+		bannedWords,
+		rules,
+		moderation.NewLinkBlocklist(moderation.Reject, blockedDomains...),
+		moderation.NewSpamScorer(),
+	)
+}
+
+// requireAdmin authenticates the request and checks the caller is an admin.
+// It writes the error response itself and returns ok=false otherwise.
+func (ac *apiConfig) requireAdmin(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return uuid.Nil, false
+	}
+
+	user, err := ac.Queries.GetUserById(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 401, "User not found")
+		return uuid.Nil, false
+	}
+
+	if !user.IsAdmin {
+		respondWithError(w, 403, "Admin access required")
+		return uuid.Nil, false
+	}
+
+	return userId, true
+}
+
+// reloadBannedWords replaces the banned words in use with the stored ones.
+func (ac *apiConfig) reloadBannedWords(ctx context.Context) error {
+	words, err := ac.Queries.GetBannedWords(ctx)
+	if err != nil {
+		return err
+	}
+
+	ac.BannedWords.Replace(words)
+	return nil
+}
+
+// runBannedWordsReloadJob reloads the banned words every
+// BANNED_WORDS_RELOAD_INTERVAL until ctx is done.
+func (ac *apiConfig) runBannedWordsReloadJob(ctx context.Context) {
+	ticker := time.NewTicker(BANNED_WORDS_RELOAD_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := ac.reloadBannedWords(ctx)
+		if err != nil {
+			log.Printf("Error reloading banned words: %v", err)
+		}
+	}
+}
+
+func (ac *apiConfig) getBannedWordsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ac.requireAdmin(w, r); !ok {
+		return
+	}
+
+	respondWithJSON(w, 200, struct {
+		Words []string `json:"words"`
+	}{
+		Words: ac.BannedWords.Words(),
+	})
+}
+
+// replaceBannedWordsHandler swaps the whole banned word list. The change is
+// stored and applied to new chirps straight away without a restart, other
+// instances reload the list when they get EVENT_BANNED_WORDS_CHANGED.
+func (ac *apiConfig) replaceBannedWordsHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	if _, ok := ac.requireAdmin(w, r); !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData struct {
+		Words []string `json:"words"`
+	}
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	err = qtx.DeleteBannedWords(r.Context())
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	words := make([]string, 0, len(reqData.Words))
+	for _, word := range reqData.Words {
+		word = moderation.NormalizeWord(word)
+		if word == "" {
+			continue
+		}
+
+		err = qtx.AddBannedWord(r.Context(), word)
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+		words = append(words, word)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	ac.BannedWords.Replace(words)
+	ac.emit(r.Context(), EVENT_BANNED_WORDS_CHANGED, nil)
+
+	respondWithJSON(w, 200, struct {
+		Words []string `json:"words"`
+	}{
+		Words: ac.BannedWords.Words(),
+	})
+}
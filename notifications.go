@@ -0,0 +1,200 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	NOTIFICATION_LIKE    = "like"
+	NOTIFICATION_REPLY   = "reply"
+	NOTIFICATION_FOLLOW  = "follow"
+	NOTIFICATION_MENTION = "mention"
+	NOTIFICATION_RECHIRP = "rechirp"
+)
+
+var notificationTypes = []string{
+	NOTIFICATION_LIKE,
+	NOTIFICATION_REPLY,
+	NOTIFICATION_FOLLOW,
+	NOTIFICATION_MENTION,
+	NOTIFICATION_RECHIRP,
+}
+
+// Notifications of these types are collapsed into a single entry when several
+// of them in a row point at the same chirp, e.g. "12 people liked your chirp".
+var groupedNotificationTypes = []string{
+	NOTIFICATION_LIKE,
+	NOTIFICATION_FOLLOW,
+	NOTIFICATION_RECHIRP,
+}
+
+// groupNotifications merges consecutive notifications of a grouped type that
+// share the same chirp. Notifications must be sorted newest first.
+func groupNotifications(notifications []database.Notification) []NotificationGroup {
+	groups := make([]NotificationGroup, 0, len(notifications))
+
+	for _, n := range notifications {
+		var chirpId *uuid.UUID
+		if n.ChirpID.Valid {
+			chirpId = &n.ChirpID.UUID
+		}
+
+		if len(groups) > 0 && slices.Contains(groupedNotificationTypes, n.Type) {
+			last := &groups[len(groups)-1]
+			if last.Type == n.Type && sameChirp(last.ChirpID, chirpId) {
+				last.IDs = append(last.IDs, n.ID)
+				if !slices.Contains(last.ActorIDs, n.ActorID) {
+					last.ActorIDs = append(last.ActorIDs, n.ActorID)
+				}
+				last.Count++
+				last.Read = last.Read && n.ReadAt.Valid
+				continue
+			}
+		}
+
+		groups = append(groups, NotificationGroup{
+			IDs:       []uuid.UUID{n.ID},
+			Type:      n.Type,
+			ChirpID:   chirpId,
+			ActorIDs:  []uuid.UUID{n.ActorID},
+			Count:     1,
+			Read:      n.ReadAt.Valid,
+			CreatedAt: n.CreatedAt,
+		})
+	}
+
+	return groups
+}
+
+func sameChirp(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func (ac *apiConfig) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetNotificationsParams{
+		UserID:     userId,
+		MaxResults: limit + 1,
+	}
+
+	if notificationType := r.URL.Query().Get("type"); notificationType != "" {
+		if !slices.Contains(notificationTypes, notificationType) {
+			respondWithError(w, 400, "Invalid notification type")
+			return
+		}
+		params.Type = sql.NullString{String: notificationType, Valid: true}
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	notifications, err := ac.Queries.GetNotifications(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	page := NotificationPage{}
+	if len(notifications) > int(limit) {
+		notifications = notifications[:limit]
+		last := notifications[len(notifications)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	page.Notifications = groupNotifications(notifications)
+
+	respondWithJSON(w, 200, page)
+}
+
+func (ac *apiConfig) getUnreadCountHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	count, err := ac.Queries.CountUnreadNotifications(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, struct {
+		Unread int64 `json:"unread"`
+	}{
+		Unread: count,
+	})
+}
+
+// markNotificationsReadHandler marks the given notification IDs as read, or
+// every notification of the caller when no IDs are sent.
+func (ac *apiConfig) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	var reqData struct {
+		IDs []uuid.UUID `json:"ids"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	err = decoder.Decode(&reqData)
+	if err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	var updated int64
+	if len(reqData.IDs) == 0 {
+		updated, err = ac.Queries.MarkAllNotificationsRead(r.Context(), userId)
+	} else {
+		updated, err = ac.Queries.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+			UserID: userId,
+			Ids:    reqData.IDs,
+		})
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, struct {
+		Updated int64 `json:"updated"`
+	}{
+		Updated: updated,
+	})
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/subscription"
+	"github.com/Alb3G/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	POLKA_SIGNATURE_HEADER = "Polka-Signature"
+	// How old, or how far in the future, a signed delivery may be
+	POLKA_SIGNATURE_TOLERANCE = time.Minute * 5
+
+	WEBHOOK_PROVIDER_POLKA = "polka"
+
+	POLKA_USER_UPGRADED         = subscription.Upgraded
+	POLKA_USER_DOWNGRADED       = subscription.Downgraded
+	POLKA_SUBSCRIPTION_RENEWED  = subscription.Renewed
+	POLKA_SUBSCRIPTION_CANCELED = subscription.Canceled
+
+	WEBHOOK_RECEIVED  = "received"
+	WEBHOOK_PROCESSED = "processed"
+	WEBHOOK_IGNORED   = "ignored"
+	WEBHOOK_FAILED    = "failed"
+)
+
+var (
+	errWebhookHandled        = errors.New("webhook event was already handled")
+	errInvalidWebhookPayload = errors.New("invalid webhook payload")
+	errWebhookUserNotFound   = errors.New("user not found")
+)
+
+func toWebhookEvent(dbEvent database.WebhookEvent) WebhookEvent {
+	event := WebhookEvent{
+		ID:              dbEvent.ID,
+		ReceivedAt:      dbEvent.ReceivedAt,
+		Provider:        dbEvent.Provider,
+		ProviderEventID: dbEvent.ProviderEventID,
+		EventType:       dbEvent.EventType,
+		Payload:         dbEvent.Payload,
+		Status:          dbEvent.Status,
+		Error:           dbEvent.Error.String,
+		Attempts:        int(dbEvent.Attempts),
+	}
+	if dbEvent.ProcessedAt.Valid {
+		event.ProcessedAt = &dbEvent.ProcessedAt.Time
+	}
+
+	return event
+}
+
+// upgradeUser handles Polka's webhooks. Deliveries must carry a valid
+// signature from one of the POLKA_WEBHOOK_SECRETS, made within
+// POLKA_SIGNATURE_TOLERANCE of now. Every signed delivery is stored in
+// webhook_events before being processed, and redeliveries of an event that
+// was already handled are acknowledged without doing anything.
+func (ac *apiConfig) upgradeUser(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	// The signature covers the raw bytes, they have to be read before decoding
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		respondWithError(w, 400, "Could not read the request body")
+		return
+	}
+
+	err = webhook.Verify(r.Header.Get(POLKA_SIGNATURE_HEADER), body, ac.PolkaSecrets, POLKA_SIGNATURE_TOLERANCE, time.Now())
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	// Undecodable payloads are still logged, processing them fails below
+	var upgradeRequest UpgradeRequest
+	json.Unmarshal(body, &upgradeRequest)
+
+	// Without an ID from Polka, identical bodies are the same event
+	eventId := upgradeRequest.ID
+	if eventId == "" {
+		sum := sha256.Sum256(body)
+		eventId = hex.EncodeToString(sum[:])
+	}
+
+	dbEvent, err := ac.Queries.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:        WEBHOOK_PROVIDER_POLKA,
+		ProviderEventID: eventId,
+		EventType:       upgradeRequest.Event,
+		Payload:         string(body),
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = ac.processWebhookEvent(r.Context(), dbEvent.ID)
+	if err != nil && !errors.Is(err, errWebhookHandled) {
+		respondWithError(w, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// processWebhookEvent applies a stored event and records the outcome. The
+// event row stays locked meanwhile, so concurrent deliveries of the same
+// event are processed once. Events already processed or ignored fail with
+// errWebhookHandled.
+func (ac *apiConfig) processWebhookEvent(ctx context.Context, id uuid.UUID) error {
+	tx, err := ac.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	dbEvent, err := qtx.LockWebhookEvent(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if dbEvent.Status == WEBHOOK_PROCESSED || dbEvent.Status == WEBHOOK_IGNORED {
+		return errWebhookHandled
+	}
+
+	status, processErr := applyPolkaEvent(ctx, qtx, []byte(dbEvent.Payload))
+	if processErr != nil {
+		// Whatever the event changed is undone, only the failure is kept
+		tx.Rollback()
+
+		err = ac.Queries.SetWebhookEventStatus(ctx, database.SetWebhookEventStatusParams{
+			Status: WEBHOOK_FAILED,
+			Error:  sql.NullString{String: processErr.Error(), Valid: true},
+			ID:     id,
+		})
+		if err != nil {
+			log.Printf("Error recording the failure of webhook event %s: %v", id, err)
+		}
+
+		return processErr
+	}
+
+	err = qtx.SetWebhookEventStatus(ctx, database.SetWebhookEventStatusParams{
+		Status: status,
+		ID:     id,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applyPolkaEvent carries out a Polka event and returns the status it
+// leaves the event in. The subscription stays locked meanwhile, the
+// transition itself is subscription.Apply. Events about subscriptions in a
+// state they don't apply to are processed without changing anything, Polka
+// may deliver them out of order.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, payload []byte) (string, error) {
+	var upgradeRequest UpgradeRequest
+	err := json.Unmarshal(payload, &upgradeRequest)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidWebhookPayload, err)
+	}
+
+	switch upgradeRequest.Event {
+	case POLKA_USER_UPGRADED, POLKA_USER_DOWNGRADED, POLKA_SUBSCRIPTION_RENEWED, POLKA_SUBSCRIPTION_CANCELED:
+	default:
+		return WEBHOOK_IGNORED, nil
+	}
+
+	userId := upgradeRequest.Data.UserId
+
+	_, err = q.GetUserById(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errWebhookUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var current *subscription.Subscription
+	dbSub, err := q.LockSubscriptionByUserId(ctx, userId)
+	if err == nil {
+		current = &subscription.Subscription{
+			Plan:              dbSub.Plan,
+			Status:            dbSub.Status,
+			CurrentPeriodEnd:  dbSub.CurrentPeriodEnd,
+			CancelAtPeriodEnd: dbSub.CancelAtPeriodEnd,
+		}
+		if dbSub.CanceledAt.Valid {
+			current.CanceledAt = &dbSub.CanceledAt.Time
+		}
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	event := subscription.Event{
+		Type:             upgradeRequest.Event,
+		Plan:             upgradeRequest.Data.Plan,
+		CurrentPeriodEnd: upgradeRequest.Data.CurrentPeriodEnd,
+	}
+	// Polka only had Chirpy Red before plans were sent
+	if event.Type == POLKA_USER_UPGRADED && event.Plan == "" {
+		event.Plan = PLAN_RED
+	}
+	if event.CurrentPeriodEnd != nil {
+		periodEnd := event.CurrentPeriodEnd.UTC()
+		event.CurrentPeriodEnd = &periodEnd
+	}
+
+	next, err := subscription.Apply(current, event, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	if next == nil {
+		return WEBHOOK_PROCESSED, nil
+	}
+
+	params := database.SaveSubscriptionParams{
+		UserID:            userId,
+		Plan:              next.Plan,
+		Status:            next.Status,
+		CurrentPeriodEnd:  next.CurrentPeriodEnd,
+		CancelAtPeriodEnd: next.CancelAtPeriodEnd,
+	}
+	if next.CanceledAt != nil {
+		params.CanceledAt = sql.NullTime{Time: *next.CanceledAt, Valid: true}
+	}
+
+	_, err = q.SaveSubscription(ctx, params)
+	if err != nil {
+		return "", err
+	}
+
+	return WEBHOOK_PROCESSED, nil
+}
+
+func webhookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidWebhookPayload), errors.Is(err, subscription.ErrUnknownPlan):
+		return 400
+	case errors.Is(err, errWebhookUserNotFound), errors.Is(err, subscription.ErrNoSubscription):
+		return 404
+	default:
+		return 500
+	}
+}
+
+// getWebhookEventsHandler lists stored webhook events with ?status, failed
+// ones by default, newest first.
+func (ac *apiConfig) getWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ac.requireAdmin(w, r); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = WEBHOOK_FAILED
+	}
+	if status != WEBHOOK_RECEIVED && status != WEBHOOK_PROCESSED && status != WEBHOOK_IGNORED && status != WEBHOOK_FAILED {
+		respondWithError(w, 400, "Invalid status. Must be 'received', 'processed', 'ignored' or 'failed'")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetWebhookEventsParams{
+		Status:     status,
+		MaxResults: limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		receivedAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeReceivedAt = sql.NullTime{Time: receivedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbEvents, err := ac.Queries.GetWebhookEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	page := WebhookEventPage{Events: make([]WebhookEvent, 0, len(dbEvents))}
+	if len(dbEvents) > int(limit) {
+		dbEvents = dbEvents[:limit]
+		last := dbEvents[len(dbEvents)-1]
+		page.NextCursor = encodeCursor(last.ReceivedAt, last.ID)
+	}
+
+	for _, dbEvent := range dbEvents {
+		page.Events = append(page.Events, toWebhookEvent(dbEvent))
+	}
+
+	respondWithJSON(w, 200, page)
+}
+
+// replayWebhookEventHandler processes a stored event again and returns it
+// with its new status. A replay that fails again is not an error of the
+// request, the event just stays failed with the new error.
+func (ac *apiConfig) replayWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ac.requireAdmin(w, r); !ok {
+		return
+	}
+
+	eventId, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid event ID format")
+		return
+	}
+
+	err = ac.processWebhookEvent(r.Context(), eventId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Webhook event not found")
+		return
+	}
+	if errors.Is(err, errWebhookHandled) {
+		respondWithError(w, 409, "Only failed events can be replayed")
+		return
+	}
+	if err != nil && webhookErrorStatus(err) == 500 {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	dbEvent, err := ac.Queries.GetWebhookEventById(r.Context(), eventId)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, toWebhookEvent(dbEvent))
+}
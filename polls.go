@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	MIN_POLL_OPTIONS       = 2
+	MAX_POLL_OPTIONS       = 4
+	MAX_POLL_OPTION_LENGTH = 25
+	MIN_POLL_DURATION      = time.Minute * 5
+	MAX_POLL_DURATION      = DAY * 7
+)
+
+var errInvalidPoll = errors.New("invalid poll")
+
+func validatePoll(poll *PollRequest) error {
+	if poll == nil {
+		return nil
+	}
+
+	if len(poll.Options) < MIN_POLL_OPTIONS || len(poll.Options) > MAX_POLL_OPTIONS {
+		return fmt.Errorf("%w: it needs between %d and %d options", errInvalidPoll, MIN_POLL_OPTIONS, MAX_POLL_OPTIONS)
+	}
+
+	seen := make(map[string]bool, len(poll.Options))
+	for _, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > MAX_POLL_OPTION_LENGTH {
+			return fmt.Errorf("%w: options must be 1 to %d characters long", errInvalidPoll, MAX_POLL_OPTION_LENGTH)
+		}
+		if seen[strings.ToLower(option)] {
+			return fmt.Errorf("%w: options must be different", errInvalidPoll)
+		}
+		seen[strings.ToLower(option)] = true
+	}
+
+	duration := time.Duration(poll.DurationMinutes) * time.Minute
+	if duration < MIN_POLL_DURATION || duration > MAX_POLL_DURATION {
+		return fmt.Errorf("%w: duration_minutes must be between %d and %d", errInvalidPoll, int(MIN_POLL_DURATION.Minutes()), int(MAX_POLL_DURATION.Minutes()))
+	}
+
+	return nil
+}
+
+// savePoll stores the poll of a new chirp. It closes duration_minutes after
+// the chirp is published.
+func savePoll(ctx context.Context, q *database.Queries, chirp database.Chirp, poll *PollRequest) error {
+	if poll == nil {
+		return nil
+	}
+
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:     chirp.ID,
+		ClosesAt:    chirp.CreatedAt.Add(time.Duration(poll.DurationMinutes) * time.Minute),
+		HideResults: poll.HideResults,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, option := range poll.Options {
+		err = q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirp.ID,
+			Position: int32(i),
+			Text:     strings.TrimSpace(option),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renderPolls fills in the polls of chirps as seen by viewer. When the author
+// asked for it, vote counts stay hidden from everyone but the author and
+// those who voted until the poll closes.
+func (ac *apiConfig) renderPolls(ctx context.Context, viewer uuid.NullUUID, chirps []Chirp) error {
+	chirpIds := make([]uuid.UUID, 0, len(chirps))
+	byId := make(map[uuid.UUID]int, len(chirps))
+	for i, chirp := range chirps {
+		chirpIds = append(chirpIds, chirp.ID)
+		byId[chirp.ID] = i
+	}
+
+	polls, err := ac.Queries.GetPollsByChirpIds(ctx, chirpIds)
+	if err != nil || len(polls) == 0 {
+		return err
+	}
+
+	results, err := ac.Queries.GetPollResultsByChirpIds(ctx, chirpIds)
+	if err != nil {
+		return err
+	}
+
+	votes := make(map[uuid.UUID]int)
+	if viewer.Valid {
+		userVotes, err := ac.Queries.GetUserPollVotes(ctx, database.GetUserPollVotesParams{
+			UserID:   viewer.UUID,
+			ChirpIds: chirpIds,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, vote := range userVotes {
+			votes[vote.ChirpID] = int(vote.Position)
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, dbPoll := range polls {
+		chirp := &chirps[byId[dbPoll.ChirpID]]
+		poll := &Poll{
+			ClosesAt:    dbPoll.ClosesAt,
+			Closed:      !dbPoll.ClosesAt.After(now),
+			HideResults: dbPoll.HideResults,
+			Options:     make([]PollOption, 0, MAX_POLL_OPTIONS),
+		}
+		if position, ok := votes[dbPoll.ChirpID]; ok {
+			poll.VotedOption = &position
+		}
+		chirp.Poll = poll
+	}
+
+	for _, result := range results {
+		chirp := &chirps[byId[result.ChirpID]]
+		poll := chirp.Poll
+
+		option := PollOption{Text: result.Text}
+
+		isAuthor := viewer.Valid && viewer.UUID == chirp.UserId
+		if !poll.HideResults || poll.Closed || poll.VotedOption != nil || isAuthor {
+			count := int(result.Votes)
+			option.Votes = &count
+
+			if poll.TotalVotes == nil {
+				poll.TotalVotes = new(int)
+			}
+			*poll.TotalVotes += count
+		}
+
+		poll.Options = append(poll.Options, option)
+	}
+
+	return nil
+}
+
+// votePollHandler records the caller's vote on the poll of a chirp they can
+// see. Each user votes once, the primary key of poll_votes guarantees it.
+func (ac *apiConfig) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, dbChirp, ok := ac.chirpTarget(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData VoteRequest
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	viewer := uuid.NullUUID{UUID: userId, Valid: true}
+
+	chirp, err := ac.renderChirp(r.Context(), viewer, dbChirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	switch {
+	case chirp.Poll == nil:
+		respondWithError(w, 404, "This chirp has no poll")
+		return
+	case chirp.Poll.Closed:
+		respondWithError(w, 409, "The poll is closed")
+		return
+	case chirp.Poll.VotedOption != nil:
+		respondWithError(w, 409, "You already voted")
+		return
+	case reqData.Option < 0 || reqData.Option >= len(chirp.Poll.Options):
+		respondWithError(w, 400, fmt.Sprintf("option must be between 0 and %d", len(chirp.Poll.Options)-1))
+		return
+	}
+
+	voted, err := ac.Queries.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		UserID:   userId,
+		Position: int32(reqData.Option),
+		ChirpID:  dbChirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if voted == 0 {
+		// Lost a race with another vote of the same user, or the poll closed
+		// in the meantime
+		respondWithError(w, 409, "You already voted or the poll is closed")
+		return
+	}
+
+	chirp, err = ac.renderChirp(r.Context(), viewer, dbChirp)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, chirp.Poll)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	REPORT_TARGET_CHIRP = "chirp"
+	REPORT_TARGET_USER  = "user"
+
+	REPORT_OPEN      = "open"
+	REPORT_RESOLVED  = "resolved"
+	REPORT_DISMISSED = "dismissed"
+
+	ACTION_HIDE_CHIRP   = "hide_chirp"
+	ACTION_DELETE_CHIRP = "delete_chirp"
+	ACTION_SUSPEND_USER = "suspend_user"
+	ACTION_DISMISS      = "dismiss"
+
+	// Reason used for the reports the moderation pipeline files by itself
+	REPORT_REASON_AUTOMATED = "automated"
+
+	CHIRP_HIDDEN = "hidden"
+
+	MAX_REPORT_DETAILS_LENGTH = 1000
+)
+
+var reportReasons = []string{
+	"spam",
+	"harassment",
+	"hate",
+	"violence",
+	"nudity",
+	"misinformation",
+	"other",
+}
+
+func toReport(dbr database.Report) Report {
+	report := Report{
+		ID:         dbr.ID,
+		CreatedAt:  dbr.CreatedAt,
+		TargetType: dbr.TargetType,
+		TargetID:   dbr.TargetID,
+		Reason:     dbr.Reason,
+		Details:    dbr.Details,
+		Status:     dbr.Status,
+	}
+
+	if dbr.ReporterID.Valid {
+		report.ReporterID = &dbr.ReporterID.UUID
+	}
+	if dbr.ResolvedAt.Valid {
+		report.ResolvedAt = &dbr.ResolvedAt.Time
+	}
+	if dbr.ResolvedBy.Valid {
+		report.ResolvedBy = &dbr.ResolvedBy.UUID
+	}
+
+	return report
+}
+
+func (ac *apiConfig) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData struct {
+		TargetType string    `json:"target_type"`
+		TargetID   uuid.UUID `json:"target_id"`
+		Reason     string    `json:"reason"`
+		Details    string    `json:"details"`
+	}
+	err = decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	if !slices.Contains(reportReasons, reqData.Reason) {
+		respondWithError(w, 400, "Invalid reason. Must be one of: "+strings.Join(reportReasons, ", "))
+		return
+	}
+
+	if utf8.RuneCountInString(reqData.Details) > MAX_REPORT_DETAILS_LENGTH {
+		respondWithError(w, 400, "Details are too long")
+		return
+	}
+
+	switch reqData.TargetType {
+	case REPORT_TARGET_CHIRP:
+		dbChirp, err := ac.Queries.GetChirpById(r.Context(), reqData.TargetID)
+		if err != nil {
+			respondWithError(w, 404, "Chirp Not found!")
+			return
+		}
+
+		visible, err := ac.canSeeChirp(r.Context(), uuid.NullUUID{UUID: userId, Valid: true}, dbChirp)
+		if err != nil {
+			respondWithError(w, 500, err.Error())
+			return
+		}
+		if !visible {
+			respondWithError(w, 404, "Chirp Not found!")
+			return
+		}
+	case REPORT_TARGET_USER:
+		_, err := ac.Queries.GetUserById(r.Context(), reqData.TargetID)
+		if err != nil {
+			respondWithError(w, 404, "User not found")
+			return
+		}
+	default:
+		respondWithError(w, 400, "target_type must be 'chirp' or 'user'")
+		return
+	}
+
+	report, err := ac.Queries.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: userId, Valid: true},
+		TargetType: reqData.TargetType,
+		TargetID:   reqData.TargetID,
+		Reason:     reqData.Reason,
+		Details:    strings.TrimSpace(reqData.Details),
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 201, toReport(report))
+}
+
+// getReportsHandler is the moderator queue: reports with the given status
+// (open by default), oldest first.
+func (ac *apiConfig) getReportsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := ac.requireAdmin(w, r); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = REPORT_OPEN
+	}
+	if status != REPORT_OPEN && status != REPORT_RESOLVED && status != REPORT_DISMISSED {
+		respondWithError(w, 400, "Invalid status. Must be 'open', 'resolved' or 'dismissed'")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.GetReportsParams{
+		Status:     status,
+		MaxResults: limit + 1,
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.AfterCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.AfterID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbReports, err := ac.Queries.GetReports(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	page := ReportPage{Reports: make([]Report, 0, len(dbReports))}
+	if len(dbReports) > int(limit) {
+		dbReports = dbReports[:limit]
+		last := dbReports[len(dbReports)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	for _, dbReport := range dbReports {
+		page.Reports = append(page.Reports, toReport(dbReport))
+	}
+
+	respondWithJSON(w, 200, page)
+}
+
+// resolveReportHandler applies a moderator's decision to the reported target
+// and closes every open report about it, or only the ones for the same
+// reason when dismissing. The action is logged with the moderator's ID and
+// reason.
+func (ac *apiConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	moderatorId, ok := ac.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	reportId, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid report ID format")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	err = decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	if strings.TrimSpace(reqData.Reason) == "" {
+		respondWithError(w, 400, "A reason is required")
+		return
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	// Locked so two moderators can't both resolve it
+	report, err := qtx.LockReport(r.Context(), reportId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Report not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	if report.Status != REPORT_OPEN {
+		respondWithError(w, 409, "Report is already closed")
+		return
+	}
+
+	// Suspending from a chirp report suspends its author
+	actionTargetType, actionTargetId := report.TargetType, report.TargetID
+	status := REPORT_RESOLVED
+	// Acting on the target closes all of its reports, dismissing only the
+	// ones for the same reason
+	resolveReason := sql.NullString{}
+	var deletedChirp database.Chirp
+
+	switch reqData.Action {
+	case ACTION_HIDE_CHIRP, ACTION_DELETE_CHIRP:
+		if report.TargetType != REPORT_TARGET_CHIRP {
+			respondWithError(w, 400, "This action only applies to chirp reports")
+			return
+		}
+
+		if reqData.Action == ACTION_HIDE_CHIRP {
+			err = qtx.SetChirpModerationStatus(r.Context(), database.SetChirpModerationStatusParams{
+				ModerationStatus: CHIRP_HIDDEN,
+				ID:               report.TargetID,
+			})
+		} else {
+			deletedChirp, err = qtx.GetChirpById(r.Context(), report.TargetID)
+			if err == nil {
+				err = qtx.DeleteChirpById(r.Context(), report.TargetID)
+			}
+			if err == nil {
+				err = queueWebhookDeliveries(r.Context(), qtx, EVENT_CHIRP_DELETED, deletedChirp)
+			} else if errors.Is(err, sql.ErrNoRows) {
+				err = nil
+			}
+		}
+	case ACTION_SUSPEND_USER:
+		if report.TargetType == REPORT_TARGET_CHIRP {
+			dbChirp, err := qtx.GetChirpById(r.Context(), report.TargetID)
+			if err != nil {
+				respondWithError(w, 404, "Reported chirp no longer exists")
+				return
+			}
+			actionTargetType, actionTargetId = REPORT_TARGET_USER, dbChirp.UserID
+		}
+
+		err = suspendUser(r, qtx, actionTargetId, sql.NullTime{})
+	case ACTION_DISMISS:
+		status = REPORT_DISMISSED
+		// Otherwise dismissing a user's report on a chirp held back by
+		// moderation would close the automated one and leave the chirp
+		// pending forever
+		resolveReason = sql.NullString{String: report.Reason, Valid: true}
+
+		// Dismissing an automated report approves the chirp it held back
+		if report.TargetType == REPORT_TARGET_CHIRP && report.Reason == REPORT_REASON_AUTOMATED {
+			err = qtx.SetChirpModerationStatus(r.Context(), database.SetChirpModerationStatusParams{
+				ModerationStatus: CHIRP_PUBLISHED,
+				ID:               report.TargetID,
+			})
+		}
+	default:
+		respondWithError(w, 400, "Invalid action. Must be 'hide_chirp', 'delete_chirp', 'suspend_user' or 'dismiss'")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	action, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ReportID:    uuid.NullUUID{UUID: report.ID, Valid: true},
+		ModeratorID: moderatorId,
+		Action:      reqData.Action,
+		TargetType:  actionTargetType,
+		TargetID:    actionTargetId,
+		Reason:      strings.TrimSpace(reqData.Reason),
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	_, err = qtx.ResolveReportsForTarget(r.Context(), database.ResolveReportsForTargetParams{
+		Status:     status,
+		ResolvedBy: uuid.NullUUID{UUID: moderatorId, Valid: true},
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		Reason:     resolveReason,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	if deletedChirp.ID != uuid.Nil {
+		ac.emit(r.Context(), EVENT_CHIRP_DELETED, toChirpEvent(deletedChirp))
+	}
+
+	respondWithJSON(w, 200, ModerationAction{
+		ID:          action.ID,
+		CreatedAt:   action.CreatedAt,
+		ReportID:    action.ReportID,
+		ModeratorID: action.ModeratorID,
+		Action:      action.Action,
+		TargetType:  action.TargetType,
+		TargetID:    action.TargetID,
+		Reason:      action.Reason,
+	})
+}
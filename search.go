@@ -0,0 +1,234 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entities"
+	"github.com/Alb3G/chirpy/internal/search"
+	"github.com/google/uuid"
+)
+
+const AUTOCOMPLETE_LIMIT = 10
+
+// Search results are ordered by rank first, so their cursors carry the rank of
+// the last result on top of the usual (created_at, id) pair.
+func encodeSearchCursor(rank float64, createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatFloat(rank, 'g', -1, 64) + "|" + encodeCursor(createdAt, id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (float64, time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	rankPart, cursorPart, found := strings.Cut(string(raw), "|")
+	if !found {
+		return 0, time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	rank, err := strconv.ParseFloat(rankPart, 64)
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	createdAt, id, err := decodeCursor(cursorPart)
+	if err != nil {
+		return 0, time.Time{}, uuid.Nil, err
+	}
+
+	return rank, createdAt, id, nil
+}
+
+func (ac *apiConfig) searchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	query, err := search.Parse(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	params := database.SearchChirpsParams{
+		Hashtags:   make([]string, 0, len(query.Hashtags)),
+		MaxResults: limit + 1,
+	}
+	params.Hashtags = append(params.Hashtags, query.Hashtags...)
+
+	if query.Text != "" {
+		params.Query = sql.NullString{String: query.Text, Valid: true}
+	}
+	if query.From != "" {
+		params.AuthorHandle = sql.NullString{String: query.From, Valid: true}
+	}
+	if query.Since != nil {
+		params.Since = sql.NullTime{Time: *query.Since, Valid: true}
+	}
+	if query.Until != nil {
+		params.Until = sql.NullTime{Time: *query.Until, Valid: true}
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		rank, createdAt, id, err := decodeSearchCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeRank = sql.NullFloat64{Float64: rank, Valid: true}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	results, err := ac.Queries.SearchChirps(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	nextCursor := ""
+	if len(results) > int(limit) {
+		results = results[:limit]
+		last := results[len(results)-1]
+		nextCursor = encodeSearchCursor(last.Rank, last.CreatedAt, last.ID)
+	}
+
+	dbChirps := make([]database.Chirp, 0, len(results))
+	for _, result := range results {
+		dbChirps = append(dbChirps, database.Chirp{
+			ID:               result.ID,
+			CreatedAt:        result.CreatedAt,
+			UpdatedAt:        result.UpdatedAt,
+			Body:             result.Body,
+			UserID:           result.UserID,
+			ModerationStatus: result.ModerationStatus,
+			Visibility:       result.Visibility,
+			PreviewUrl:       result.PreviewUrl,
+			EditedAt:         result.EditedAt,
+		})
+	}
+
+	viewer := ac.viewerId(r)
+	dbChirps, err = ac.filterChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	chirps, err := ac.renderChirps(r.Context(), viewer, dbChirps)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+	})
+}
+
+func (ac *apiConfig) searchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(w, 400, "Empty search query")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	rows, err := ac.Queries.SearchUsers(r.Context(), database.SearchUsersParams{
+		Query:        query,
+		HandlePrefix: escapeLike(entities.NormalizeHandle(query)),
+		MaxResults:   limit,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	blocked, err := ac.blockedUsers(r.Context(), ac.viewerId(r))
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	users := make([]PublicUser, 0, len(rows))
+	for _, row := range rows {
+		if blocked[row.ID] {
+			continue
+		}
+		users = append(users, PublicUser{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName.String,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	err = ac.addBadges(r.Context(), users)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, users)
+}
+
+func (ac *apiConfig) autocompleteHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := entities.NormalizeHandle(r.URL.Query().Get("prefix"))
+	if !entities.ValidHandle(prefix) {
+		respondWithJSON(w, 200, []PublicUser{})
+		return
+	}
+
+	rows, err := ac.Queries.AutocompleteHandles(r.Context(), database.AutocompleteHandlesParams{
+		HandlePrefix: escapeLike(prefix),
+		MaxResults:   AUTOCOMPLETE_LIMIT,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	blocked, err := ac.blockedUsers(r.Context(), ac.viewerId(r))
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	users := make([]PublicUser, 0, len(rows))
+	for _, row := range rows {
+		if blocked[row.ID] {
+			continue
+		}
+		users = append(users, PublicUser{
+			ID:          row.ID,
+			Handle:      row.Handle.String,
+			DisplayName: row.DisplayName.String,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	err = ac.addBadges(r.Context(), users)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, users)
+}
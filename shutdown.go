@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// connTracker keeps count of the long-lived connections (SSE streams and
+// WebSockets) so they can be told to wind down on shutdown. http.Server's
+// Shutdown never returns for streams and doesn't know about hijacked
+// WebSockets at all.
+type connTracker struct {
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+	closed   bool
+	draining chan struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{draining: make(chan struct{})}
+}
+
+// track registers a new connection, it reports false once draining has
+// started and the connection should be refused.
+func (ct *connTracker) track() bool {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	if ct.closed {
+		return false
+	}
+	ct.wg.Add(1)
+
+	return true
+}
+
+func (ct *connTracker) done() {
+	ct.wg.Done()
+}
+
+// Draining is closed when connections should say goodbye and return.
+func (ct *connTracker) Draining() <-chan struct{} {
+	return ct.draining
+}
+
+// drain asks every connection to close and waits for them until ctx is done.
+func (ct *connTracker) drain(ctx context.Context) error {
+	ct.mu.Lock()
+	if !ct.closed {
+		ct.closed = true
+		close(ct.draining)
+	}
+	ct.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		ct.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
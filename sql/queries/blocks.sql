@@ -0,0 +1,30 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+values ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+-- name: DeleteBlock :exec
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+-- name: BlockExists :one
+SELECT EXISTS (
+	SELECT 1 FROM blocks
+	WHERE (blocker_id = sqlc.arg(user_a) AND blocked_id = sqlc.arg(user_b))
+	OR (blocker_id = sqlc.arg(user_b) AND blocked_id = sqlc.arg(user_a))
+);
+-- name: GetBlockedUserIds :many
+SELECT blocked_id AS user_id FROM blocks WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id AS user_id FROM blocks WHERE blocked_id = sqlc.arg(user_id);
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+values ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+-- name: DeleteMute :exec
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+-- name: GetHiddenUserIds :many
+SELECT blocked_id AS user_id FROM blocks WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id AS user_id FROM blocks WHERE blocked_id = sqlc.arg(user_id)
+UNION
+SELECT muted_id AS user_id FROM mutes WHERE muter_id = sqlc.arg(user_id);
@@ -0,0 +1,17 @@
+-- name: CreateBookmark :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+values ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+-- name: DeleteBookmark :exec
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+-- name: GetBookmarkedChirps :many
+SELECT chirps.*, bookmarks.created_at AS bookmarked_at FROM bookmarks
+INNER JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+AND (
+	sqlc.narg(before_created_at)::timestamp IS NULL
+	OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(max_results);
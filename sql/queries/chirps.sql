@@ -1,6 +1,6 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-values (gen_random_uuid(), NOW(), NOW(), $1, $2) RETURNING *;
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5) RETURNING *;
 -- name: GetChirps :many
 SELECT * FROM chirps ORDER BY created_at;
 -- name: GetChirpById :one
@@ -11,4 +11,13 @@ WHERE id = $1;
 -- name: GetChirpsByUserId :many
 SELECT * FROM chirps
 WHERE user_id = $1
-ORDER BY created_at;
\ No newline at end of file
+ORDER BY created_at;
+-- name: SetChirpModerationStatus :exec
+UPDATE chirps
+SET moderation_status = $1, updated_at = NOW()
+WHERE id = $2;
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, moderation_status = $2, preview_url = $3, edited_at = NOW(), updated_at = NOW()
+WHERE id = $4
+RETURNING *;
@@ -0,0 +1,69 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, creator_id, is_group, direct_key)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3)
+ON CONFLICT (direct_key) DO NOTHING
+RETURNING *;
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+values ($1, $2, NOW());
+-- name: GetConversationByDirectKey :one
+SELECT * FROM conversations WHERE direct_key = $1;
+-- name: GetConversationById :one
+SELECT * FROM conversations WHERE id = $1;
+-- name: GetConversationMembers :many
+SELECT
+	conversation_members.conversation_id,
+	conversation_members.user_id,
+	conversation_members.last_read_at,
+	users.handle,
+	users.display_name
+FROM conversation_members
+INNER JOIN users ON users.id = conversation_members.user_id
+WHERE conversation_members.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY conversation_members.conversation_id, conversation_members.joined_at, conversation_members.user_id;
+-- name: GetUserConversations :many
+SELECT
+	conversations.*,
+	(
+		SELECT COUNT(*) FROM messages
+		WHERE messages.conversation_id = conversations.id
+		AND messages.sender_id <> sqlc.arg(user_id)
+		AND (conversation_members.last_read_at IS NULL OR messages.created_at > conversation_members.last_read_at)
+	)::int AS unread_count
+FROM conversations
+INNER JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = sqlc.arg(user_id)
+AND (
+	sqlc.narg(before_updated_at)::timestamp IS NULL
+	OR (conversations.updated_at, conversations.id) < (sqlc.narg(before_updated_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY conversations.updated_at DESC, conversations.id DESC
+LIMIT sqlc.arg(max_results);
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+values (gen_random_uuid(), NOW(), $1, $2, $3) RETURNING *;
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id)
+AND (
+	sqlc.narg(before_created_at)::timestamp IS NULL
+	OR (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
+-- name: GetLatestMessage :one
+SELECT * FROM messages
+WHERE conversation_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT 1;
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = GREATEST(COALESCE(last_read_at, sqlc.arg(read_at)::timestamp), sqlc.arg(read_at)::timestamp)
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id);
+-- name: GetMessageById :one
+SELECT * FROM messages
+WHERE id = $1 AND conversation_id = $2;
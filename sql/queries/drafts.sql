@@ -0,0 +1,33 @@
+-- name: CreateDraft :one
+INSERT INTO chirp_drafts (id, created_at, updated_at, user_id, body, visibility, media_ids, publish_at, poll_options, poll_duration_minutes, poll_hide_results)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
+-- name: GetDrafts :many
+SELECT * FROM chirp_drafts
+WHERE user_id = sqlc.arg(user_id)
+AND (sqlc.narg(scheduled)::boolean IS NULL OR (publish_at IS NOT NULL) = sqlc.narg(scheduled)::boolean)
+ORDER BY created_at DESC, id DESC;
+-- name: GetDraftById :one
+SELECT * FROM chirp_drafts
+WHERE id = $1 AND user_id = $2;
+-- name: UpdateDraft :one
+UPDATE chirp_drafts
+SET body = $3, visibility = $4, media_ids = $5, publish_at = $6, poll_options = $7, poll_duration_minutes = $8, poll_hide_results = $9, last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+-- name: DeleteDraft :execrows
+DELETE FROM chirp_drafts
+WHERE id = $1 AND user_id = $2;
+-- name: ClaimDueDraft :one
+SELECT * FROM chirp_drafts
+WHERE publish_at <= NOW()
+ORDER BY publish_at
+LIMIT 1
+FOR UPDATE SKIP LOCKED;
+-- name: FailDraft :exec
+UPDATE chirp_drafts
+SET publish_at = NULL, last_error = $2, updated_at = NOW()
+WHERE id = $1;
+-- name: LockDraft :one
+SELECT * FROM chirp_drafts
+WHERE id = $1 AND user_id = $2
+FOR UPDATE;
@@ -0,0 +1,39 @@
+-- name: UpsertHashtag :one
+INSERT INTO hashtags (id, tag, created_at)
+values (gen_random_uuid(), $1, NOW())
+ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+RETURNING *;
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id)
+values ($1, $2)
+ON CONFLICT DO NOTHING;
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+INNER JOIN chirp_hashtags ON chirps.id = chirp_hashtags.chirp_id
+INNER JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+WHERE hashtags.tag = sqlc.arg(tag)
+AND (
+	sqlc.narg(before_created_at)::timestamp IS NULL
+	OR (chirps.created_at, chirps.id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(max_results);
+-- name: GetHashtagTrends :many
+SELECT
+	hashtags.tag,
+	COUNT(*)::int AS uses,
+	SUM(POWER(0.5, EXTRACT(EPOCH FROM (NOW() - chirps.created_at)) / sqlc.arg(half_life_seconds)::float8))::float8 AS score
+FROM chirp_hashtags
+INNER JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+INNER JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+INNER JOIN users ON users.id = chirps.user_id
+WHERE chirps.created_at > sqlc.arg(since)::timestamp
+AND chirps.visibility = 'public'
+AND chirps.moderation_status = 'published'
+AND NOT users.shadow_banned
+GROUP BY hashtags.tag
+ORDER BY score DESC, hashtags.tag
+LIMIT sqlc.arg(max_results)::int;
@@ -0,0 +1,24 @@
+-- name: GetPendingPreviewUrls :many
+SELECT DISTINCT chirps.preview_url::text FROM chirps
+LEFT JOIN link_previews ON link_previews.url = chirps.preview_url
+WHERE chirps.preview_url IS NOT NULL
+AND chirps.created_at > sqlc.arg(since)::timestamp
+AND (
+	link_previews.url IS NULL
+	OR (link_previews.ok AND link_previews.fetched_at < sqlc.arg(stale_before)::timestamp)
+	OR (NOT link_previews.ok AND link_previews.fetched_at < sqlc.arg(retry_before)::timestamp)
+)
+LIMIT sqlc.arg(max_results);
+-- name: UpsertLinkPreview :exec
+INSERT INTO link_previews (url, fetched_at, ok, title, description, image_url, site_name)
+values ($1, NOW(), $2, $3, $4, $5, $6)
+ON CONFLICT (url) DO UPDATE
+SET fetched_at = NOW(),
+	ok = EXCLUDED.ok,
+	title = EXCLUDED.title,
+	description = EXCLUDED.description,
+	image_url = EXCLUDED.image_url,
+	site_name = EXCLUDED.site_name;
+-- name: GetLinkPreviewsByUrls :many
+SELECT * FROM link_previews
+WHERE url = ANY(sqlc.arg(urls)::text[]) AND ok;
@@ -0,0 +1,47 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, description, is_private)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4) RETURNING *;
+-- name: GetListById :one
+SELECT * FROM lists WHERE id = $1;
+-- name: LockList :one
+SELECT * FROM lists
+WHERE id = $1
+FOR UPDATE;
+-- name: GetListsByOwner :many
+SELECT * FROM lists
+WHERE owner_id = sqlc.arg(owner_id)
+AND (sqlc.arg(include_private)::bool OR NOT is_private)
+ORDER BY created_at;
+-- name: UpdateList :one
+UPDATE lists
+SET name = $1, description = $2, is_private = $3, updated_at = NOW()
+WHERE id = $4
+RETURNING *;
+-- name: DeleteList :exec
+DELETE FROM lists
+WHERE id = $1;
+-- name: AddListMember :execrows
+INSERT INTO list_members (list_id, user_id, added_at)
+values ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+-- name: GetListMembers :many
+SELECT users.id, users.handle, users.display_name, users.created_at FROM list_members
+INNER JOIN users ON users.id = list_members.user_id
+WHERE list_members.list_id = $1
+ORDER BY list_members.added_at;
+-- name: GetListChirps :many
+SELECT chirps.* FROM chirps
+INNER JOIN list_members ON list_members.user_id = chirps.user_id
+WHERE list_members.list_id = sqlc.arg(list_id)
+AND (
+	sqlc.narg(before_created_at)::timestamp IS NULL
+	OR (chirps.created_at, chirps.id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,24 @@
+-- name: CreateMediaUpload :one
+INSERT INTO media_uploads (id, created_at, user_id, mime_type, width, height, size_bytes, blob_key, thumbnail_key)
+values ($1, NOW(), $2, $3, $4, $5, $6, $7, $8) RETURNING *;
+-- name: GetMediaUploadById :one
+SELECT * FROM media_uploads WHERE id = $1;
+-- name: AttachMediaUpload :execrows
+UPDATE media_uploads
+SET chirp_id = sqlc.arg(chirp_id), position = sqlc.arg(position)
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id) AND chirp_id IS NULL;
+-- name: GetMediaUploadsByChirpIds :many
+SELECT * FROM media_uploads
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+-- name: GetOrphanedMediaUploads :many
+SELECT * FROM media_uploads
+WHERE chirp_id IS NULL AND created_at < sqlc.arg(before)::timestamp
+AND NOT EXISTS (
+	SELECT 1 FROM chirp_drafts
+	WHERE media_uploads.id = ANY(chirp_drafts.media_ids)
+)
+ORDER BY created_at
+LIMIT sqlc.arg(max_results);
+-- name: DeleteMediaUpload :exec
+DELETE FROM media_uploads WHERE id = $1;
@@ -0,0 +1,24 @@
+-- name: CreateChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, start_offset, end_offset)
+values ($1, $2, $3, $4);
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+-- name: GetMentionedUserIds :many
+SELECT DISTINCT user_id FROM chirp_mentions
+WHERE chirp_id = $1;
+-- name: GetMentionsByChirpIds :many
+SELECT
+	chirp_mentions.chirp_id,
+	chirp_mentions.user_id,
+	chirp_mentions.start_offset,
+	chirp_mentions.end_offset,
+	users.handle
+FROM chirp_mentions
+INNER JOIN users ON users.id = chirp_mentions.user_id
+WHERE chirp_mentions.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_mentions.chirp_id, chirp_mentions.start_offset;
+-- name: GetChirpIdsMentioningUser :many
+SELECT DISTINCT chirp_id FROM chirp_mentions
+WHERE user_id = sqlc.arg(user_id)
+AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
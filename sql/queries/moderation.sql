@@ -0,0 +1,9 @@
+-- name: GetBannedWords :many
+SELECT word FROM banned_words
+ORDER BY word;
+-- name: DeleteBannedWords :exec
+DELETE FROM banned_words;
+-- name: AddBannedWord :exec
+INSERT INTO banned_words (word, created_at)
+values ($1, NOW())
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,32 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, created_at, closes_at, hide_results)
+values ($1, NOW(), $2, $3);
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+values ($1, $2, $3);
+-- name: GetPollByChirpId :one
+SELECT * FROM polls WHERE chirp_id = $1;
+-- name: GetPollsByChirpIds :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+-- name: GetPollResultsByChirpIds :many
+SELECT
+	poll_options.chirp_id,
+	poll_options.position,
+	poll_options.text,
+	COUNT(poll_votes.user_id)::int AS votes
+FROM poll_options
+LEFT JOIN poll_votes
+ON poll_votes.chirp_id = poll_options.chirp_id AND poll_votes.position = poll_options.position
+WHERE poll_options.chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+GROUP BY poll_options.chirp_id, poll_options.position, poll_options.text
+ORDER BY poll_options.chirp_id, poll_options.position;
+-- name: GetUserPollVotes :many
+SELECT chirp_id, position FROM poll_votes
+WHERE user_id = sqlc.arg(user_id)
+AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[]);
+-- name: CreatePollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+SELECT polls.chirp_id, sqlc.arg(user_id), sqlc.arg(position), NOW() FROM polls
+WHERE polls.chirp_id = sqlc.arg(chirp_id) AND polls.closes_at > NOW()
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
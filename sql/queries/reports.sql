@@ -0,0 +1,24 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, target_type, target_id, reason, details)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5) RETURNING *;
+-- name: LockReport :one
+SELECT * FROM reports
+WHERE id = $1
+FOR UPDATE;
+-- name: GetReports :many
+SELECT * FROM reports
+WHERE status = sqlc.arg(status)
+AND (
+	sqlc.narg(after_created_at)::timestamp IS NULL
+	OR (created_at, id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY created_at, id
+LIMIT sqlc.arg(max_results);
+-- name: ResolveReportsForTarget :execrows
+UPDATE reports
+SET status = $1, resolved_at = NOW(), resolved_by = $2, updated_at = NOW()
+WHERE target_type = $3 AND target_id = $4 AND status = 'open'
+AND (sqlc.narg(reason)::text IS NULL OR reason = sqlc.narg(reason)::text);
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, report_id, moderator_id, action, target_type, target_id, reason)
+values (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6) RETURNING *;
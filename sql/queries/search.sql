@@ -0,0 +1,41 @@
+-- name: SearchChirps :many
+SELECT id, created_at, updated_at, body, user_id, moderation_status, visibility, preview_url, edited_at, rank FROM (
+	SELECT
+		chirps.id,
+		chirps.created_at,
+		chirps.updated_at,
+		chirps.body,
+		chirps.user_id,
+		chirps.moderation_status,
+		chirps.visibility,
+		chirps.preview_url,
+		chirps.edited_at,
+		(CASE
+			WHEN sqlc.narg(query)::text IS NULL THEN 0
+			ELSE ts_rank(to_tsvector('english', chirps.body), websearch_to_tsquery('english', sqlc.narg(query)::text))
+		END)::float8 AS rank
+	FROM chirps
+	INNER JOIN users ON users.id = chirps.user_id
+	WHERE (
+		sqlc.narg(query)::text IS NULL
+		OR to_tsvector('english', chirps.body) @@ websearch_to_tsquery('english', sqlc.narg(query)::text)
+	)
+	AND (sqlc.narg(author_handle)::text IS NULL OR users.handle = sqlc.narg(author_handle)::text)
+	AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since)::timestamp)
+	AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until)::timestamp)
+	AND (
+		cardinality(sqlc.arg(hashtags)::text[]) = 0
+		OR (
+			SELECT COUNT(*) FROM chirp_hashtags
+			INNER JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+			WHERE chirp_hashtags.chirp_id = chirps.id
+			AND hashtags.tag = ANY(sqlc.arg(hashtags)::text[])
+		) = cardinality(sqlc.arg(hashtags)::text[])
+	)
+) AS results
+WHERE (
+	sqlc.narg(before_rank)::float8 IS NULL
+	OR (rank, created_at, id) < (sqlc.narg(before_rank)::float8, sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
@@ -0,0 +1,24 @@
+-- name: GetSubscriptionByUserId :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+-- name: LockSubscriptionByUserId :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+-- name: GetActivePlansByUserIds :many
+SELECT user_id, plan FROM subscriptions
+WHERE user_id = ANY(sqlc.arg(user_ids)::uuid[]) AND status = 'active' AND current_period_end > NOW();
+-- name: SaveSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end, cancel_at_period_end, canceled_at)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6)
+ON CONFLICT (user_id) DO UPDATE SET
+	updated_at = NOW(),
+	plan = EXCLUDED.plan,
+	status = EXCLUDED.status,
+	current_period_end = EXCLUDED.current_period_end,
+	cancel_at_period_end = EXCLUDED.cancel_at_period_end,
+	canceled_at = EXCLUDED.canceled_at
+RETURNING *;
+-- name: ExpireSubscriptions :execrows
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status = 'active' AND current_period_end <= NOW();
@@ -1,4 +0,0 @@
--- name: UpgradeUserById :execrows
-UPDATE users
-SET is_chirpy_red = '1'
-WHERE id = $1;
\ No newline at end of file
@@ -1,12 +1,66 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_pass)
-values (gen_random_uuid(), NOW(), NOW(), $1, $2) RETURNING *;
+INSERT INTO users (id, created_at, updated_at, email, hashed_pass, handle, display_name)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4) RETURNING *;
 -- name: DeleteUsers :exec
 DELETE FROM users;
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
 -- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_pass = $2, updated_at = NOW()
-WHERE id = $3
-RETURNING *;
\ No newline at end of file
+SET email = sqlc.arg(email),
+	hashed_pass = sqlc.arg(hashed_pass),
+	handle = COALESCE(sqlc.narg(handle), handle),
+	display_name = COALESCE(sqlc.narg(display_name), display_name),
+	accepts_dms = COALESCE(sqlc.narg(accepts_dms), accepts_dms),
+	updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+-- name: GetUsersByHandles :many
+SELECT * FROM users
+WHERE handle = ANY(sqlc.arg(handles)::text[]);
+-- name: SearchUsers :many
+SELECT id, handle, display_name, created_at FROM users
+WHERE (
+	handle % sqlc.arg(query)::text
+	OR display_name % sqlc.arg(query)::text
+	OR handle LIKE sqlc.arg(handle_prefix)::text || '%'
+)
+AND (suspended_at IS NULL OR suspended_until <= NOW())
+AND NOT shadow_banned
+ORDER BY GREATEST(
+	similarity(COALESCE(handle, ''), sqlc.arg(query)::text),
+	similarity(COALESCE(display_name, ''), sqlc.arg(query)::text)
+) DESC, handle
+LIMIT sqlc.arg(max_results);
+-- name: AutocompleteHandles :many
+SELECT id, handle, display_name, created_at FROM users
+WHERE handle LIKE sqlc.arg(handle_prefix)::text || '%'
+AND (suspended_at IS NULL OR suspended_until <= NOW())
+AND NOT shadow_banned
+ORDER BY handle
+LIMIT sqlc.arg(max_results);
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = $1;
+-- name: SuspendUser :execrows
+UPDATE users
+SET suspended_at = NOW(), suspended_until = sqlc.narg(suspended_until), updated_at = NOW()
+WHERE id = sqlc.arg(id);
+-- name: UnsuspendUser :execrows
+UPDATE users
+SET suspended_at = NULL, suspended_until = NULL, updated_at = NOW()
+WHERE id = $1;
+-- name: SetUserShadowBanned :execrows
+UPDATE users
+SET shadow_banned = $1, updated_at = NOW()
+WHERE id = $2;
+-- name: GetShadowBannedUserIds :many
+SELECT id FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]) AND shadow_banned;
+-- name: PinChirp :exec
+UPDATE users
+SET pinned_chirp_id = $1, updated_at = NOW()
+WHERE id = $2;
+-- name: UnpinChirp :execrows
+UPDATE users
+SET pinned_chirp_id = NULL, updated_at = NOW()
+WHERE id = $1 AND pinned_chirp_id = $2;
@@ -0,0 +1,51 @@
+-- name: CreateWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), webhook_endpoints.id, sqlc.arg(event_id), sqlc.arg(event_type), sqlc.arg(payload), NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.user_id = sqlc.arg(user_id)
+AND sqlc.arg(event_type)::text = ANY(webhook_endpoints.event_types);
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until), updated_at = NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+AND webhook_deliveries.id IN (
+	SELECT id FROM webhook_deliveries
+	WHERE status = 'pending' AND next_attempt_at <= NOW()
+	ORDER BY next_attempt_at
+	LIMIT sqlc.arg(max_results)
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING
+	webhook_deliveries.id,
+	webhook_deliveries.event_id,
+	webhook_deliveries.event_type,
+	webhook_deliveries.payload,
+	webhook_deliveries.attempts,
+	webhook_endpoints.url,
+	webhook_endpoints.secret;
+-- name: SetWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', attempts = attempts + 1, last_status_code = $1, last_error = NULL, delivered_at = NOW(), updated_at = NOW()
+WHERE id = $2;
+-- name: SetWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_status_code = $3, last_error = $4, updated_at = NOW()
+WHERE id = $5;
+-- name: GetWebhookDeliveryById :one
+SELECT * FROM webhook_deliveries WHERE id = $1 AND endpoint_id = $2;
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+AND (
+	sqlc.narg(before_created_at)::timestamp IS NULL
+	OR (created_at, id) < (sqlc.narg(before_created_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
+-- name: RetryWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,14 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, event_types)
+values (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+RETURNING *;
+-- name: GetWebhookEndpointById :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+-- name: GetWebhookEndpointsByUserId :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC;
+-- name: CountWebhookEndpoints :one
+SELECT COUNT(*) FROM webhook_endpoints WHERE user_id = $1;
+-- name: DeleteWebhookEndpoint :exec
+DELETE FROM webhook_endpoints WHERE id = $1;
@@ -0,0 +1,24 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, received_at, provider, provider_event_id, event_type, payload)
+values (gen_random_uuid(), NOW(), $1, $2, $3, $4)
+ON CONFLICT (provider, provider_event_id) DO UPDATE SET provider = EXCLUDED.provider
+RETURNING *;
+-- name: GetWebhookEventById :one
+SELECT * FROM webhook_events WHERE id = $1;
+-- name: LockWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+-- name: SetWebhookEventStatus :exec
+UPDATE webhook_events
+SET status = $1, error = $2, attempts = attempts + 1, processed_at = NOW()
+WHERE id = $3;
+-- name: GetWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE status = sqlc.arg(status)
+AND (
+	sqlc.narg(before_received_at)::timestamp IS NULL
+	OR (received_at, id) < (sqlc.narg(before_received_at)::timestamp, sqlc.narg(before_id)::uuid)
+)
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg(max_results);
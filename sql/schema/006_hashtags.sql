@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE hashtags(
+	id UUID PRIMARY KEY,
+	tag TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL
+);
+CREATE TABLE chirp_hashtags(
+	chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+	hashtag_id UUID NOT NULL REFERENCES hashtags(id) ON DELETE CASCADE,
+	PRIMARY KEY (chirp_id, hashtag_id)
+);
+CREATE INDEX chirp_hashtags_hashtag_id_idx ON chirp_hashtags(hashtag_id);
+CREATE INDEX chirps_created_at_idx ON chirps(created_at);
+-- +goose Down
+DROP INDEX chirps_created_at_idx;
+DROP TABLE chirp_hashtags;
+DROP TABLE hashtags;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users
+ADD handle TEXT UNIQUE;
+-- +goose Down
+ALTER TABLE users DROP handle;
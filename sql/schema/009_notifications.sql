@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE notifications(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	type TEXT NOT NULL,
+	chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+	read_at TIMESTAMP
+);
+CREATE INDEX notifications_user_id_created_at_idx ON notifications(user_id, created_at);
+-- +goose Down
+DROP TABLE notifications;
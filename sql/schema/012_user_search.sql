@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+ALTER TABLE users
+ADD display_name TEXT;
+CREATE INDEX users_handle_trgm_idx ON users USING GIN (handle gin_trgm_ops);
+CREATE INDEX users_display_name_trgm_idx ON users USING GIN (display_name gin_trgm_ops);
+CREATE INDEX users_handle_prefix_idx ON users (handle text_pattern_ops);
+-- +goose Down
+DROP INDEX users_handle_prefix_idx;
+DROP INDEX users_display_name_trgm_idx;
+DROP INDEX users_handle_trgm_idx;
+ALTER TABLE users DROP display_name;
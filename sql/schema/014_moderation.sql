@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE users
+ADD is_admin BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE chirps
+ADD moderation_status TEXT NOT NULL DEFAULT 'published';
+CREATE TABLE banned_words(
+	word TEXT PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL
+);
+INSERT INTO banned_words (word, created_at)
+values ('kerfuffle', NOW()), ('sharbert', NOW()), ('fornax', NOW());
+-- +goose Down
+DROP TABLE banned_words;
+ALTER TABLE chirps DROP moderation_status;
+ALTER TABLE users DROP is_admin;
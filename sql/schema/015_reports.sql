@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE users
+ADD suspended_at TIMESTAMP;
+CREATE TABLE reports(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+	target_type TEXT NOT NULL,
+	target_id UUID NOT NULL,
+	reason TEXT NOT NULL,
+	details TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'open',
+	resolved_at TIMESTAMP,
+	resolved_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+CREATE INDEX reports_status_created_at_idx ON reports(status, created_at);
+CREATE INDEX reports_target_idx ON reports(target_type, target_id);
+-- Actions are an audit log, so they keep plain IDs that outlive the rows
+-- they point at.
+CREATE TABLE moderation_actions(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	report_id UUID,
+	moderator_id UUID NOT NULL,
+	action TEXT NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id UUID NOT NULL,
+	reason TEXT NOT NULL
+);
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+ALTER TABLE users DROP suspended_at;
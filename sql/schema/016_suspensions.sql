@@ -0,0 +1,6 @@
+-- +goose Up
+ALTER TABLE users
+ADD suspended_until TIMESTAMP,
+ADD shadow_banned BOOLEAN NOT NULL DEFAULT false;
+-- +goose Down
+ALTER TABLE users DROP shadow_banned, DROP suspended_until;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD visibility TEXT NOT NULL DEFAULT 'public';
+-- +goose Down
+ALTER TABLE chirps DROP visibility;
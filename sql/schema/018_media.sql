@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE media_uploads(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+	position INTEGER NOT NULL DEFAULT 0,
+	mime_type TEXT NOT NULL,
+	width INTEGER NOT NULL,
+	height INTEGER NOT NULL,
+	size_bytes INTEGER NOT NULL,
+	blob_key TEXT NOT NULL,
+	thumbnail_key TEXT NOT NULL
+);
+CREATE INDEX media_uploads_chirp_idx ON media_uploads(chirp_id, position);
+CREATE INDEX media_uploads_orphaned_idx ON media_uploads(created_at) WHERE chirp_id IS NULL;
+-- +goose Down
+DROP TABLE media_uploads;
@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD preview_url TEXT;
+CREATE TABLE link_previews(
+	url TEXT PRIMARY KEY,
+	fetched_at TIMESTAMP NOT NULL,
+	ok BOOLEAN NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	image_url TEXT NOT NULL DEFAULT '',
+	site_name TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX chirps_preview_url_idx ON chirps(created_at) WHERE preview_url IS NOT NULL;
+-- +goose Down
+DROP TABLE link_previews;
+ALTER TABLE chirps DROP preview_url;
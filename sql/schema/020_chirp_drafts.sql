@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE chirp_drafts(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	body TEXT NOT NULL,
+	visibility TEXT NOT NULL,
+	media_ids UUID[] NOT NULL DEFAULT '{}',
+	publish_at TIMESTAMP,
+	last_error TEXT
+);
+CREATE INDEX chirp_drafts_user_idx ON chirp_drafts(user_id, created_at);
+CREATE INDEX chirp_drafts_due_idx ON chirp_drafts(publish_at) WHERE publish_at IS NOT NULL;
+-- +goose Down
+DROP TABLE chirp_drafts;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE polls(
+	chirp_id UUID PRIMARY KEY REFERENCES chirps(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	closes_at TIMESTAMP NOT NULL,
+	hide_results BOOLEAN NOT NULL DEFAULT false
+);
+CREATE TABLE poll_options(
+	chirp_id UUID NOT NULL REFERENCES polls(chirp_id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	text TEXT NOT NULL,
+	PRIMARY KEY (chirp_id, position)
+);
+-- The primary key is what makes votes unique per user, even when the same
+-- user votes from two requests at once.
+CREATE TABLE poll_votes(
+	chirp_id UUID NOT NULL,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (chirp_id, user_id),
+	FOREIGN KEY (chirp_id, position) REFERENCES poll_options(chirp_id, position) ON DELETE CASCADE
+);
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
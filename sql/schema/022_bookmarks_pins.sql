@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE bookmarks(
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, chirp_id)
+);
+CREATE INDEX bookmarks_user_created_idx ON bookmarks(user_id, created_at DESC, chirp_id DESC);
+ALTER TABLE users
+ADD pinned_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+-- +goose Down
+ALTER TABLE users DROP pinned_chirp_id;
+DROP TABLE bookmarks;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE lists(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	is_private BOOLEAN NOT NULL DEFAULT false
+);
+CREATE INDEX lists_owner_idx ON lists(owner_id, created_at);
+CREATE TABLE list_members(
+	list_id UUID NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	added_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (list_id, user_id)
+);
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
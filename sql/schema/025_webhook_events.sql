@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE webhook_events(
+	id UUID PRIMARY KEY,
+	received_at TIMESTAMP NOT NULL,
+	provider TEXT NOT NULL,
+	provider_event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL DEFAULT 'received',
+	error TEXT,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	processed_at TIMESTAMP,
+	UNIQUE (provider, provider_event_id)
+);
+CREATE INDEX webhook_events_status_idx ON webhook_events(status, received_at DESC, id DESC);
+-- +goose Down
+DROP TABLE webhook_events;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+	id UUID PRIMARY KEY,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL,
+	user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+	plan TEXT NOT NULL,
+	status TEXT NOT NULL,
+	current_period_end TIMESTAMP NOT NULL,
+	cancel_at_period_end BOOLEAN NOT NULL DEFAULT false,
+	canceled_at TIMESTAMP
+);
+CREATE INDEX subscriptions_active_idx ON subscriptions(current_period_end) WHERE status = 'active';
+-- Upgrades used to be forever, existing members keep Red for life: their
+-- period ends so far ahead that it never lapses
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan, status, current_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', '9999-12-31'::timestamp
+FROM users WHERE is_chirpy_red;
+ALTER TABLE users DROP is_chirpy_red;
+-- +goose Down
+ALTER TABLE users
+ADD is_chirpy_red BOOLEAN DEFAULT '0';
+UPDATE users SET is_chirpy_red = '1'
+WHERE id IN (SELECT user_id FROM subscriptions WHERE status = 'active' AND current_period_end > NOW());
+DROP TABLE subscriptions;
@@ -0,0 +1,4 @@
+-- +goose Up
+ALTER TABLE chirps ADD edited_at TIMESTAMP;
+-- +goose Down
+ALTER TABLE chirps DROP edited_at;
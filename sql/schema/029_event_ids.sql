@@ -0,0 +1,5 @@
+-- +goose Up
+-- Numbers the events of the Postgres event bus across every instance
+CREATE SEQUENCE chirpy_event_ids;
+-- +goose Down
+DROP SEQUENCE chirpy_event_ids;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- No options means the draft has no poll
+ALTER TABLE chirp_drafts
+ADD poll_options TEXT[],
+ADD poll_duration_minutes INTEGER NOT NULL DEFAULT 0,
+ADD poll_hide_results BOOLEAN NOT NULL DEFAULT false;
+-- +goose Down
+ALTER TABLE chirp_drafts DROP poll_options, DROP poll_duration_minutes, DROP poll_hide_results;
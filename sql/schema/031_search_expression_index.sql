@@ -0,0 +1,11 @@
+-- +goose Up
+-- The stored column came back with every chirp query, index the expression
+-- search matches on instead
+DROP INDEX chirps_search_vector_idx;
+ALTER TABLE chirps DROP search_vector;
+CREATE INDEX chirps_search_idx ON chirps USING GIN (to_tsvector('english', body));
+-- +goose Down
+DROP INDEX chirps_search_idx;
+ALTER TABLE chirps
+ADD search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+CREATE INDEX chirps_search_vector_idx ON chirps USING GIN (search_vector);
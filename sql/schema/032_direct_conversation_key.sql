@@ -0,0 +1,22 @@
+-- +goose Up
+-- The two members of a 1:1 conversation, ordered bytewise like in Go, so
+-- there is only ever one per pair. NULL for groups.
+ALTER TABLE conversations
+ADD direct_key TEXT;
+UPDATE conversations SET direct_key = pairs.direct_key
+FROM (
+	SELECT DISTINCT ON (direct_key) conversation_id, direct_key FROM (
+		SELECT conversation_id, MIN(user_id::text COLLATE "C") || ':' || MAX(user_id::text COLLATE "C") AS direct_key
+		FROM conversation_members
+		GROUP BY conversation_id
+	) AS keys
+	INNER JOIN conversations ON conversations.id = keys.conversation_id
+	WHERE NOT conversations.is_group
+	-- Duplicates created before keep their messages, new ones go to the oldest
+	ORDER BY direct_key, conversations.created_at
+) AS pairs
+WHERE conversations.id = pairs.conversation_id;
+CREATE UNIQUE INDEX conversations_direct_key_idx ON conversations(direct_key);
+-- +goose Down
+DROP INDEX conversations_direct_key_idx;
+ALTER TABLE conversations DROP direct_key;
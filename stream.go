@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+const (
+	// Events kept for clients resuming with Last-Event-ID
+	STREAM_BUFFER_SIZE = 1024
+	// Events a connection may fall behind by before it is dropped
+	STREAM_SUBSCRIBER_BUFFER = 64
+	STREAM_HEARTBEAT         = time.Second * 15
+	STREAM_WRITE_TIMEOUT     = time.Second * 10
+	STREAM_RETRY_MS          = 3000
+	// How long a connection trusts the blocks and mutes of its viewer
+	STREAM_VIEWER_TTL = time.Second * 30
+)
+
+// streamChirp is a chirp event as the hub carries it. The chirp is loaded
+// and rendered once per event, along with what the visibility rules need
+// to know about it, so connections only check what depends on their
+// viewer. Deleted chirps are not rendered.
+type streamChirp struct {
+	Chirp database.Chirp
+	// As an anonymous viewer sees it, poll results included
+	Rendered           Chirp
+	AuthorShadowBanned bool
+	Mentioned          map[uuid.UUID]bool
+}
+
+// newStreamChirp prepares dbChirp for the hub.
+func (ac *apiConfig) newStreamChirp(ctx context.Context, eventType string, dbChirp database.Chirp) (streamChirp, error) {
+	sc := streamChirp{
+		Chirp:     dbChirp,
+		Mentioned: make(map[uuid.UUID]bool),
+	}
+
+	shadowBanned, err := ac.Queries.GetShadowBannedUserIds(ctx, []uuid.UUID{dbChirp.UserID})
+	if err != nil {
+		return streamChirp{}, err
+	}
+	sc.AuthorShadowBanned = len(shadowBanned) > 0
+
+	if eventType == EVENT_CHIRP_DELETED {
+		return sc, nil
+	}
+
+	sc.Rendered, err = ac.renderChirp(ctx, uuid.NullUUID{}, dbChirp)
+	if err != nil {
+		return streamChirp{}, err
+	}
+	for _, mention := range sc.Rendered.Entities.Mentions {
+		sc.Mentioned[mention.UserID] = true
+	}
+
+	return sc, nil
+}
+
+// streamViewer is what a realtime connection keeps about its viewer to
+// filter events without querying for each of them. The users they blocked
+// or muted are reloaded every STREAM_VIEWER_TTL.
+type streamViewer struct {
+	ID       uuid.NullUUID
+	hidden   map[uuid.UUID]bool
+	loadedAt time.Time
+}
+
+func newStreamViewer(viewer uuid.NullUUID) *streamViewer {
+	return &streamViewer{ID: viewer, hidden: make(map[uuid.UUID]bool)}
+}
+
+// canSee reports whether the viewer can see the chirp of sc.
+func (ac *apiConfig) canSee(ctx context.Context, sv *streamViewer, sc streamChirp) (bool, error) {
+	if sv.ID.Valid && time.Since(sv.loadedAt) > STREAM_VIEWER_TTL {
+		hiddenIds, err := ac.Queries.GetHiddenUserIds(ctx, sv.ID.UUID)
+		if err != nil {
+			return false, err
+		}
+
+		sv.hidden = make(map[uuid.UUID]bool, len(hiddenIds))
+		for _, id := range hiddenIds {
+			sv.hidden[id] = true
+		}
+		sv.loadedAt = time.Now()
+	}
+
+	mentioned := sv.ID.Valid && sc.Mentioned[sv.ID.UUID]
+	return chirpVisible(sv.ID, sc.Chirp, sv.hidden[sc.Chirp.UserID], sc.AuthorShadowBanned, mentioned), nil
+}
+
+// streamChirpsHandler pushes created, edited and deleted chirps as
+// Server-Sent Events, optionally only those of ?author_id. Every event goes through the
+// same visibility checks as the listings for the caller. A connection that
+// can't keep up is closed, the client then reconnects with Last-Event-ID
+// and gets the events it missed from the hub's buffer.
+func (ac *apiConfig) streamChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	var authorId uuid.NullUUID
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, 400, "Invalid author_id was provided")
+			return
+		}
+		authorId = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var lastId uint64
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId != "" {
+		id, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			respondWithError(w, 400, "Invalid Last-Event-ID")
+			return
+		}
+		lastId = id
+	}
+
+	if !ac.conns.track() {
+		respondWithError(w, 503, "Server is shutting down")
+		return
+	}
+	defer ac.conns.done()
+
+	viewer := newStreamViewer(ac.viewerId(r))
+
+	sub, missed := ac.Stream.Subscribe(lastId, lastEventId != "")
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	_, err := fmt.Fprintf(w, "retry: %d\n\n", STREAM_RETRY_MS)
+	if err == nil {
+		err = rc.Flush()
+	}
+	if err != nil {
+		return
+	}
+
+	send := func(event string) error {
+		// A client that stopped reading must not hold the handler forever
+		rc.SetWriteDeadline(time.Now().Add(STREAM_WRITE_TIMEOUT))
+		_, err := fmt.Fprint(w, event)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for _, event := range missed {
+		msg, ok := ac.formatChirpEvent(r.Context(), viewer, authorId, event)
+		if !ok {
+			continue
+		}
+		if err := send(msg); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(STREAM_HEARTBEAT)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ac.conns.Draining():
+			// Clients reconnect to another instance and resume from there
+			return
+		case <-heartbeat.C:
+			if err := send(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				log.Printf("Closing chirp stream: %v", sub.Err())
+				return
+			}
+
+			msg, ok := ac.formatChirpEvent(r.Context(), viewer, authorId, event)
+			if !ok {
+				continue
+			}
+			if err := send(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// formatChirpEvent renders a hub event as an SSE message for viewer, or
+// reports false if it shouldn't be sent to them.
+func (ac *apiConfig) formatChirpEvent(ctx context.Context, viewer *streamViewer, authorId uuid.NullUUID, event pubsub.Event) (string, bool) {
+	data, ok := ac.chirpEventData(ctx, viewer, authorId, event)
+	if !ok {
+		return "", false
+	}
+
+	dat, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error parsing json object: %v", err)
+		return "", false
+	}
+
+	return fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, dat), true
+}
+
+// chirpEventData is the payload of a chirp event as viewer gets it: the
+// rendered chirp for creations and edits, only its ID and author for
+// deletions. It reports false for events that aren't about chirps, or about
+// chirps viewer can't see.
+func (ac *apiConfig) chirpEventData(ctx context.Context, viewer *streamViewer, authorId uuid.NullUUID, event pubsub.Event) (any, bool) {
+	sc, ok := event.Payload.(streamChirp)
+	if !ok {
+		return nil, false
+	}
+
+	if authorId.Valid && sc.Chirp.UserID != authorId.UUID {
+		return nil, false
+	}
+
+	visible, err := ac.canSee(ctx, viewer, sc)
+	if err != nil {
+		log.Printf("Error checking chirp %s for the stream: %v", sc.Chirp.ID, err)
+		return nil, false
+	}
+	if !visible {
+		return nil, false
+	}
+
+	switch event.Type {
+	case EVENT_CHIRP_CREATED, EVENT_CHIRP_UPDATED:
+		return sc.Rendered, true
+	case EVENT_CHIRP_DELETED:
+		return struct {
+			ID       uuid.UUID `json:"id"`
+			AuthorID uuid.UUID `json:"author_id"`
+		}{
+			ID:       sc.Chirp.ID,
+			AuthorID: sc.Chirp.UserID,
+		}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entitlements"
+	"github.com/Alb3G/chirpy/internal/subscription"
+	"github.com/google/uuid"
+)
+
+const (
+	PLAN_RED = entitlements.PlanRed
+
+	SUBSCRIPTION_ACTIVE   = subscription.StatusActive
+	SUBSCRIPTION_CANCELED = subscription.StatusCanceled
+	SUBSCRIPTION_EXPIRED  = subscription.StatusExpired
+
+	SUBSCRIPTION_INTERVAL = time.Minute * 10
+)
+
+// isActiveSubscription reports whether sub grants its plan at now. The
+// period end is checked too, so lapsed subscriptions stop counting before
+// the expiry job gets to them.
+func isActiveSubscription(sub *database.Subscription, now time.Time) bool {
+	return sub != nil && sub.Status == SUBSCRIPTION_ACTIVE && sub.CurrentPeriodEnd.After(now)
+}
+
+// userSubscription returns the subscription of userId, nil if they never had
+// one.
+func (ac *apiConfig) userSubscription(ctx context.Context, userId uuid.UUID) (*database.Subscription, error) {
+	sub, err := ac.Queries.GetSubscriptionByUserId(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func toSubscription(sub *database.Subscription) *Subscription {
+	if sub == nil {
+		return nil
+	}
+
+	return &Subscription{
+		Plan:              sub.Plan,
+		Status:            sub.Status,
+		CurrentPeriodEnd:  sub.CurrentPeriodEnd,
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+	}
+}
+
+// runSubscriptionExpiryJob marks lapsed subscriptions as expired every
+// SUBSCRIPTION_INTERVAL until ctx is done.
+func (ac *apiConfig) runSubscriptionExpiryJob(ctx context.Context) {
+	ticker := time.NewTicker(SUBSCRIPTION_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		expired, err := ac.Queries.ExpireSubscriptions(ctx)
+		if err != nil {
+			log.Printf("Error expiring subscriptions: %v", err)
+		} else if expired > 0 {
+			log.Printf("Expired %d subscriptions", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
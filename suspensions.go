@@ -0,0 +1,190 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	ACTION_UNSUSPEND_USER = "unsuspend_user"
+	ACTION_SHADOW_BAN     = "shadow_ban"
+	ACTION_LIFT_SHADOWBAN = "lift_shadow_ban"
+)
+
+var (
+	errAccountSuspended = errors.New("account suspended")
+	errInvalidDuration  = errors.New("duration must be a positive Go duration like 72h")
+)
+
+// isSuspended reports whether dbu is suspended at now. A suspension without
+// an end date lasts until it is lifted by an admin.
+func isSuspended(dbu database.User, now time.Time) bool {
+	if !dbu.SuspendedAt.Valid {
+		return false
+	}
+
+	return !dbu.SuspendedUntil.Valid || dbu.SuspendedUntil.Time.After(now)
+}
+
+// suspendUser suspends userId until the given time, or indefinitely when
+// until is not valid, and revokes all of their refresh tokens.
+func suspendUser(r *http.Request, q *database.Queries, userId uuid.UUID, until sql.NullTime) error {
+	_, err := q.SuspendUser(r.Context(), database.SuspendUserParams{
+		SuspendedUntil: until,
+		ID:             userId,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.RevokeUserTokens(r.Context(), userId)
+}
+
+// userModeration is the shared body of the admin endpoints acting on a user.
+type userModeration struct {
+	Reason string `json:"reason"`
+	// Optional for suspensions, e.g. "72h". Leaving it out suspends the
+	// account indefinitely.
+	Duration string `json:"duration"`
+}
+
+// moderateUser runs apply against the {userID} of the request inside a
+// transaction and records it as a moderation action by the calling admin.
+func (ac *apiConfig) moderateUser(w http.ResponseWriter, r *http.Request, action string, apply func(q *database.Queries, userId uuid.UUID, reqData userModeration) error) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	moderatorId, ok := ac.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid user ID format")
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData userModeration
+	err = decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return
+	}
+
+	if strings.TrimSpace(reqData.Reason) == "" {
+		respondWithError(w, 400, "A reason is required")
+		return
+	}
+
+	_, err = ac.Queries.GetUserById(r.Context(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	tx, err := ac.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	qtx := ac.Queries.WithTx(tx)
+
+	err = apply(qtx, userId, reqData)
+	if errors.Is(err, errInvalidDuration) {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	dbAction, err := qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		ModeratorID: moderatorId,
+		Action:      action,
+		TargetType:  REPORT_TARGET_USER,
+		TargetID:    userId,
+		Reason:      strings.TrimSpace(reqData.Reason),
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, ModerationAction{
+		ID:          dbAction.ID,
+		CreatedAt:   dbAction.CreatedAt,
+		ReportID:    dbAction.ReportID,
+		ModeratorID: dbAction.ModeratorID,
+		Action:      dbAction.Action,
+		TargetType:  dbAction.TargetType,
+		TargetID:    dbAction.TargetID,
+		Reason:      dbAction.Reason,
+	})
+}
+
+func (ac *apiConfig) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	ac.moderateUser(w, r, ACTION_SUSPEND_USER, func(q *database.Queries, userId uuid.UUID, reqData userModeration) error {
+		until := sql.NullTime{}
+		if reqData.Duration != "" {
+			duration, err := time.ParseDuration(reqData.Duration)
+			if err != nil || duration <= 0 {
+				return errInvalidDuration
+			}
+			until = sql.NullTime{Time: time.Now().UTC().Add(duration), Valid: true}
+		}
+
+		return suspendUser(r, q, userId, until)
+	})
+}
+
+func (ac *apiConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	ac.moderateUser(w, r, ACTION_UNSUSPEND_USER, func(q *database.Queries, userId uuid.UUID, reqData userModeration) error {
+		_, err := q.UnsuspendUser(r.Context(), userId)
+		return err
+	})
+}
+
+// shadowBanHandler makes the user's chirps visible only to themselves without
+// telling them.
+func (ac *apiConfig) shadowBanHandler(w http.ResponseWriter, r *http.Request) {
+	ac.moderateUser(w, r, ACTION_SHADOW_BAN, func(q *database.Queries, userId uuid.UUID, reqData userModeration) error {
+		_, err := q.SetUserShadowBanned(r.Context(), database.SetUserShadowBannedParams{
+			ShadowBanned: true,
+			ID:           userId,
+		})
+		return err
+	})
+}
+
+func (ac *apiConfig) liftShadowBanHandler(w http.ResponseWriter, r *http.Request) {
+	ac.moderateUser(w, r, ACTION_LIFT_SHADOWBAN, func(q *database.Queries, userId uuid.UUID, reqData userModeration) error {
+		_, err := q.SetUserShadowBanned(r.Context(), database.SetUserShadowBannedParams{
+			ShadowBanned: false,
+			ID:           userId,
+		})
+		return err
+	})
+}
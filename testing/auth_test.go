@@ -68,6 +68,31 @@ func TestMakeAndValidateJWT(t *testing.T) {
 	}
 }
 
+func TestParseJWTExpiry(t *testing.T) {
+	userId := uuid.MustParse("fb68025f-be8f-4649-aa15-0c2b6b1c6409")
+	secret := "test-secret-key"
+
+	before := time.Now()
+	token, err := auth.MakeJWT(userId, secret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed signing JWT: %v", err)
+	}
+
+	parsedUserId, expiresAt, err := auth.ParseJWT(token, secret)
+	if err != nil {
+		t.Fatalf("Failed parsing JWT: %v", err)
+	}
+
+	if parsedUserId != userId {
+		t.Errorf("Expected user %v, got %v", userId, parsedUserId)
+	}
+
+	// JWT times have a one second resolution
+	if expiresAt.Before(before.Add(time.Hour-time.Second)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("Unexpected expiration time %v", expiresAt)
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	testBearer := "Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"
 	expectedResult := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9"
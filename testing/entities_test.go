@@ -0,0 +1,56 @@
+package testing
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Alb3G/chirpy/internal/entities"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	body := "Learning #Go today, #go is fun! c#sharp #123 #café_au_lait"
+
+	hashtags := entities.ExtractHashtags(body)
+	if len(hashtags) != 3 {
+		t.Fatalf("Expected 3 hashtags, got %d: %v", len(hashtags), hashtags)
+	}
+
+	for _, hashtag := range hashtags {
+		if body[hashtag.Start] != '#' {
+			t.Errorf("Offset %d should point at a '#'", hashtag.Start)
+		}
+	}
+
+	if body[hashtags[0].Start:hashtags[0].End] != "#Go" {
+		t.Errorf("Unexpected span %q", body[hashtags[0].Start:hashtags[0].End])
+	}
+
+	tags := entities.UniqueTags(hashtags)
+	expected := []string{"go", "café_au_lait"}
+	if !slices.Equal(tags, expected) {
+		t.Errorf("Result: %v expected to be equal to %v", tags, expected)
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	if tag := entities.NormalizeTag(" #GoLang"); tag != "golang" {
+		t.Errorf("Result: %v expected to be equal to golang", tag)
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	body := "hey @Alice and @bob_99, mail me at me@example.com @"
+
+	mentions := entities.ExtractMentions(body)
+	if len(mentions) != 2 {
+		t.Fatalf("Expected 2 mentions, got %d: %v", len(mentions), mentions)
+	}
+
+	if mentions[0].Handle != "alice" || body[mentions[0].Start:mentions[0].End] != "@Alice" {
+		t.Errorf("Unexpected first mention %+v", mentions[0])
+	}
+
+	if mentions[1].Handle != "bob_99" {
+		t.Errorf("Result: %v expected to be equal to bob_99", mentions[1].Handle)
+	}
+}
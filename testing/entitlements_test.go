@@ -0,0 +1,38 @@
+package testing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/entitlements"
+)
+
+func TestForPlanFallsBackToFree(t *testing.T) {
+	if plan := entitlements.ForPlan("platinum").Plan; plan != entitlements.PlanFree {
+		t.Errorf("Result: %v expected to be equal to %v", plan, entitlements.PlanFree)
+	}
+
+	free := entitlements.ForPlan(entitlements.PlanFree)
+	red := entitlements.ForPlan(entitlements.PlanRed)
+	if red.MaxChirpLength <= free.MaxChirpLength || red.MaxChirpMedia <= free.MaxChirpMedia {
+		t.Errorf("Red should allow more than free: %+v %+v", red, free)
+	}
+	if free.Badge != "" || red.Badge == "" {
+		t.Errorf("Only red should come with a badge: %q %q", free.Badge, red.Badge)
+	}
+}
+
+func TestCanEdit(t *testing.T) {
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	red := entitlements.ForPlan(entitlements.PlanRed)
+
+	if !red.CanEdit(createdAt, createdAt.Add(red.EditWindow)) {
+		t.Error("Edits should be allowed until the window ends")
+	}
+	if red.CanEdit(createdAt, createdAt.Add(red.EditWindow+time.Second)) {
+		t.Error("Edits should be rejected once the window ended")
+	}
+	if entitlements.ForPlan(entitlements.PlanFree).CanEdit(createdAt, createdAt) {
+		t.Error("The free plan shouldn't allow edits")
+	}
+}
@@ -0,0 +1,53 @@
+package testing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Alb3G/chirpy/internal/events"
+)
+
+func TestMemoryBusDelivers(t *testing.T) {
+	bus := events.NewMemory()
+	defer bus.Close()
+
+	var received []events.Event
+	unsubscribe := bus.Subscribe(func(event events.Event) {
+		received = append(received, event)
+	})
+
+	event, err := events.New("chirp.created", map[string]string{"id": "42"})
+	if err != nil {
+		t.Fatalf("Failed encoding event: %v", err)
+	}
+
+	err = bus.Publish(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Failed publishing event: %v", err)
+	}
+
+	if len(received) != 1 || received[0].Type != "chirp.created" {
+		t.Fatalf("Expected the event to be delivered, got %+v", received)
+	}
+	if received[0].ID == 0 {
+		t.Errorf("Expected the bus to assign an ID")
+	}
+
+	var data map[string]string
+	err = received[0].Decode(&data)
+	if err != nil || data["id"] != "42" {
+		t.Errorf("Expected the data to round-trip, got %v (%v)", data, err)
+	}
+
+	bus.Publish(context.Background(), event)
+	if len(received) != 2 || received[1].ID <= received[0].ID {
+		t.Errorf("Expected increasing IDs, got %+v", received)
+	}
+
+	unsubscribe()
+	bus.Publish(context.Background(), event)
+
+	if len(received) != 2 {
+		t.Errorf("Expected no delivery after unsubscribing, got %d events", len(received))
+	}
+}
@@ -0,0 +1,181 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/Alb3G/chirpy/internal/blobstore"
+	"github.com/Alb3G/chirpy/internal/media"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, 0, color.RGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// withExif splices an APP1 segment with the given orientation right after
+// the SOI marker of a JPEG.
+func withExif(jpegData []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big endian header, IFD at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // Orientation SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(payload) + 2
+
+	segment := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, segment...)
+	return append(out, jpegData[2:]...)
+}
+
+func TestSniffRejectsNonImages(t *testing.T) {
+	_, err := media.Sniff([]byte("<html><script>alert(1)</script></html>"))
+	if !errors.Is(err, media.ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType, got %v", err)
+	}
+
+	mimeType, err := media.Sniff(encodePNG(t, 2, 2))
+	if err != nil || mimeType != "image/png" {
+		t.Errorf("Expected image/png, got %q (%v)", mimeType, err)
+	}
+}
+
+func TestProcessStripsExifAndRotates(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 6 means the camera was rotated: the picture is displayed 90° clockwise
+	result, err := media.Process(withExif(buf.Bytes(), 6))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Width != 20 || result.Height != 40 {
+		t.Errorf("Expected a 20x40 image after rotation, got %dx%d", result.Width, result.Height)
+	}
+
+	if bytes.Contains(result.Data, []byte("Exif")) {
+		t.Error("Processed image still contains EXIF data")
+	}
+}
+
+func TestProcessThumbnail(t *testing.T) {
+	result, err := media.Process(encodePNG(t, 1000, 500))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thumbnail, err := jpeg.DecodeConfig(bytes.NewReader(result.Thumbnail))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if thumbnail.Width != media.ThumbnailSize || thumbnail.Height != media.ThumbnailSize/2 {
+		t.Errorf("Expected a %dx%d thumbnail, got %dx%d", media.ThumbnailSize, media.ThumbnailSize/2, thumbnail.Width, thumbnail.Height)
+	}
+}
+
+func encodeGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+
+	anim := &gif.GIF{}
+	for range frames {
+		frame := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.Black, color.White})
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestProcessGIFFrameBudget(t *testing.T) {
+	result, err := media.Process(encodeGIF(t, 8, 8, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MimeType != "image/gif" {
+		t.Errorf("Expected image/gif, got %q", result.MimeType)
+	}
+
+	_, err = media.Process(encodeGIF(t, 1, 1, media.MaxGIFFrames+1))
+	if !errors.Is(err, media.ErrTooManyFrames) {
+		t.Errorf("Expected ErrTooManyFrames for too many frames, got %v", err)
+	}
+
+	// Without its trailer the frames can't be counted, it must not be
+	// decoded anyway
+	truncated := encodeGIF(t, 1, 1, media.MaxGIFFrames+100)
+	_, err = media.Process(truncated[:len(truncated)-1])
+	if !errors.Is(err, media.ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for a truncated GIF, got %v", err)
+	}
+
+	// Few frames, but together over the pixel budget
+	_, err = media.Process(encodeGIF(t, 5000, 5000, 10))
+	if !errors.Is(err, media.ErrTooManyFrames) {
+		t.Errorf("Expected ErrTooManyFrames over the pixel budget, got %v", err)
+	}
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(ctx, "media/a/original", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := store.Get(ctx, "media/a/original")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(data) != "hello" {
+		t.Errorf("Expected hello, got %q", data)
+	}
+
+	if err := store.Delete(ctx, "media/a/original"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "media/a/original"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+
+	if err := store.Put(ctx, "../escape", []byte("x"), "text/plain"); err == nil {
+		t.Error("Expected keys escaping the root to be rejected")
+	}
+}
@@ -0,0 +1,66 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/Alb3G/chirpy/internal/moderation"
+)
+
+func TestWordListMasksWithPunctuation(t *testing.T) {
+	words := moderation.NewWordList(moderation.Mask, "kerfuffle", "sharbert")
+
+	decision := words.Check("What a  Kérfuffle! Such SHARBERT, wow")
+	expected := "What a  ****! Such ****, wow"
+
+	if decision.Action != moderation.Mask {
+		t.Fatalf("Expected mask action, got %v", decision.Action)
+	}
+
+	if decision.Body != expected {
+		t.Errorf("Result: %q expected to be equal to %q", decision.Body, expected)
+	}
+}
+
+func TestWordListRuntimeEdit(t *testing.T) {
+	words := moderation.NewWordList(moderation.Mask, "fornax")
+	words.Replace([]string{"gizmo"})
+
+	if decision := words.Check("fornax"); decision.Action != moderation.Allow {
+		t.Errorf("Removed word should be allowed, got %v", decision.Action)
+	}
+
+	if decision := words.Check("gizmo"); decision.Action != moderation.Mask {
+		t.Errorf("Added word should be masked, got %v", decision.Action)
+	}
+}
+
+func TestPipelineOrder(t *testing.T) {
+	pipeline := moderation.NewPipeline(
+		moderation.NewWordList(moderation.Mask, "kerfuffle"),
+		moderation.NewLinkBlocklist(moderation.Reject, "spam.example"),
+		moderation.NewSpamScorer(),
+	)
+
+	decision := pipeline.Run("kerfuffle at https://promo.spam.example/win")
+	if decision.Action != moderation.Reject || decision.Filter != "link_blocklist" {
+		t.Errorf("Expected link_blocklist to reject, got %v from %q", decision.Action, decision.Filter)
+	}
+
+	decision = pipeline.Run("kerfuffle at https://example.org")
+	if decision.Action != moderation.Mask || decision.Body != "**** at https://example.org" {
+		t.Errorf("Unexpected decision %+v", decision)
+	}
+}
+
+func TestSpamScorer(t *testing.T) {
+	scorer := moderation.NewSpamScorer()
+
+	if decision := scorer.Check("Just had a nice walk in the park"); decision.Action != moderation.Allow {
+		t.Errorf("Expected allow, got %v", decision.Action)
+	}
+
+	spam := "FREEEEEEEEE MONEY CLICK NOW a.example b.example c.example"
+	if decision := scorer.Check(spam); decision.Action < moderation.Review {
+		t.Errorf("Expected review or reject, got %v (score %d)", decision.Action, moderation.SpamScore(spam))
+	}
+}
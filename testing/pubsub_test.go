@@ -0,0 +1,71 @@
+package testing
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Alb3G/chirpy/internal/pubsub"
+)
+
+func TestHubDeliversToSubscribers(t *testing.T) {
+	hub := pubsub.NewHub(8, 8)
+
+	sub, missed := hub.Subscribe(0, false)
+	defer sub.Close()
+	if len(missed) != 0 {
+		t.Fatalf("Expected no missed events without resume, got %d", len(missed))
+	}
+
+	published := hub.Publish(1, "chirp.created", "a")
+
+	event := <-sub.Events()
+	if event.ID != published.ID || event.Type != "chirp.created" || event.Payload != "a" {
+		t.Errorf("Unexpected event %+v, expected %+v", event, published)
+	}
+}
+
+func TestHubResumesAfterLastEventID(t *testing.T) {
+	hub := pubsub.NewHub(3, 8)
+
+	var ids []uint64
+	for i, payload := range []string{"a", "b", "c", "d"} {
+		ids = append(ids, hub.Publish(uint64(100+i), "chirp.created", payload).ID)
+	}
+
+	sub, missed := hub.Subscribe(ids[1], true)
+	defer sub.Close()
+
+	if len(missed) != 2 || missed[0].Payload != "c" || missed[1].Payload != "d" {
+		t.Fatalf("Expected events c and d to be replayed, got %+v", missed)
+	}
+
+	// "a" fell out of the buffer, only what is left can be replayed
+	_, missed = hub.Subscribe(ids[0]-1, true)
+	if len(missed) != 3 || missed[0].Payload != "b" {
+		t.Errorf("Expected the 3 buffered events to be replayed, got %+v", missed)
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := pubsub.NewHub(8, 2)
+
+	sub, _ := hub.Subscribe(0, false)
+	for i := range 3 {
+		hub.Publish(uint64(i+1), "chirp.created", nil)
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+
+	if received != 2 {
+		t.Errorf("Expected the 2 buffered events before closing, got %d", received)
+	}
+	if !errors.Is(sub.Err(), pubsub.ErrSlowSubscriber) {
+		t.Errorf("Expected ErrSlowSubscriber, got %v", sub.Err())
+	}
+
+	// Closing a dropped subscription is harmless
+	sub.Close()
+}
@@ -0,0 +1,45 @@
+package testing
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/search"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	query, err := search.Parse(`"hello world" from:@Alice #Go since:2024-01-01 until:2024-01-31 -spam`)
+	if err != nil {
+		t.Fatalf("Test case failed with error: %v", err)
+	}
+
+	if query.Text != `"hello world" -spam` {
+		t.Errorf("Result: %q expected to be equal to %q", query.Text, `"hello world" -spam`)
+	}
+
+	if query.From != "alice" {
+		t.Errorf("Result: %v expected to be equal to alice", query.From)
+	}
+
+	if !slices.Equal(query.Hashtags, []string{"go"}) {
+		t.Errorf("Result: %v expected to be equal to [go]", query.Hashtags)
+	}
+
+	if query.Since == nil || !query.Since.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected since date %v", query.Since)
+	}
+
+	// until: is inclusive, so the upper bound is the start of the next day
+	if query.Until == nil || !query.Until.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected until date %v", query.Until)
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	for _, raw := range []string{"", "   ", "since:yesterday", "from:not-a-handle"} {
+		if _, err := search.Parse(raw); err == nil {
+			t.Errorf("Expected an error parsing %q", raw)
+		}
+	}
+}
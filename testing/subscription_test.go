@@ -0,0 +1,142 @@
+package testing
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/entitlements"
+	"github.com/Alb3G/chirpy/internal/subscription"
+)
+
+var subscriptionNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+const subscriptionDay = time.Hour * 24
+
+func applySubscription(t *testing.T, sub *subscription.Subscription, event subscription.Event, now time.Time) *subscription.Subscription {
+	t.Helper()
+
+	next, err := subscription.Apply(sub, event, now)
+	if err != nil {
+		t.Fatalf("Applying %s: %v", event.Type, err)
+	}
+
+	return next
+}
+
+func TestSubscriptionUpgrade(t *testing.T) {
+	sub := applySubscription(t, nil, subscription.Event{Type: subscription.Upgraded, Plan: entitlements.PlanRed}, subscriptionNow)
+	if sub == nil || sub.Status != subscription.StatusActive || sub.Plan != entitlements.PlanRed {
+		t.Fatalf("Expected an active red subscription, got %+v", sub)
+	}
+	if !sub.CurrentPeriodEnd.Equal(subscriptionNow.Add(subscription.Period)) {
+		t.Errorf("Expected the period to end in %v, got %v", subscription.Period, sub.CurrentPeriodEnd)
+	}
+
+	for _, plan := range []string{"platinum", entitlements.PlanFree} {
+		_, err := subscription.Apply(nil, subscription.Event{Type: subscription.Upgraded, Plan: plan}, subscriptionNow)
+		if !errors.Is(err, subscription.ErrUnknownPlan) {
+			t.Errorf("Expected ErrUnknownPlan upgrading to %q, got %v", plan, err)
+		}
+	}
+}
+
+func TestSubscriptionRenewAfterLapse(t *testing.T) {
+	canceledAt := subscriptionNow.Add(-subscriptionDay * 40)
+	lapsed := &subscription.Subscription{
+		Plan:              entitlements.PlanRed,
+		Status:            subscription.StatusExpired,
+		CurrentPeriodEnd:  subscriptionNow.Add(-subscriptionDay * 10),
+		CancelAtPeriodEnd: true,
+		CanceledAt:        &canceledAt,
+	}
+
+	sub := applySubscription(t, lapsed, subscription.Event{Type: subscription.Renewed}, subscriptionNow)
+	if sub.Status != subscription.StatusActive {
+		t.Errorf("Expected the renewal to reactivate the subscription, got %q", sub.Status)
+	}
+	// From now, the lapsed days aren't paid for
+	if !sub.CurrentPeriodEnd.Equal(subscriptionNow.Add(subscription.Period)) {
+		t.Errorf("Expected the period to start again now, got %v", sub.CurrentPeriodEnd)
+	}
+	if sub.CancelAtPeriodEnd || sub.CanceledAt != nil {
+		t.Errorf("Expected the renewal to undo the cancellation, got %+v", sub)
+	}
+}
+
+func TestSubscriptionCancelThenDowngrade(t *testing.T) {
+	sub := applySubscription(t, nil, subscription.Event{Type: subscription.Upgraded, Plan: entitlements.PlanRed}, subscriptionNow)
+
+	canceledAt := subscriptionNow.Add(subscriptionDay)
+	sub = applySubscription(t, sub, subscription.Event{Type: subscription.Canceled}, canceledAt)
+	if sub.Status != subscription.StatusActive || !sub.CancelAtPeriodEnd {
+		t.Fatalf("Expected an active subscription ending with the period, got %+v", sub)
+	}
+
+	downgradedAt := subscriptionNow.Add(subscriptionDay * 2)
+	sub = applySubscription(t, sub, subscription.Event{Type: subscription.Downgraded}, downgradedAt)
+	if sub.Status != subscription.StatusCanceled {
+		t.Errorf("Expected a canceled subscription, got %q", sub.Status)
+	}
+	if !sub.CurrentPeriodEnd.Equal(downgradedAt) {
+		t.Errorf("Expected the period to end at the downgrade, got %v", sub.CurrentPeriodEnd)
+	}
+	if sub.CanceledAt == nil || !sub.CanceledAt.Equal(canceledAt) {
+		t.Errorf("Expected the first cancellation time to be kept, got %v", sub.CanceledAt)
+	}
+}
+
+func TestSubscriptionOutOfOrderEvents(t *testing.T) {
+	// A renewal before the upgrade fails, so it can be replayed after it
+	_, err := subscription.Apply(nil, subscription.Event{Type: subscription.Renewed}, subscriptionNow)
+	if !errors.Is(err, subscription.ErrNoSubscription) {
+		t.Errorf("Expected ErrNoSubscription, got %v", err)
+	}
+
+	// Cancellations and downgrades of nothing active change nothing
+	ended := &subscription.Subscription{
+		Plan:             entitlements.PlanRed,
+		Status:           subscription.StatusCanceled,
+		CurrentPeriodEnd: subscriptionNow,
+	}
+	for _, eventType := range []string{subscription.Canceled, subscription.Downgraded} {
+		if sub := applySubscription(t, ended, subscription.Event{Type: eventType}, subscriptionNow); sub != nil {
+			t.Errorf("Expected %s to change nothing, got %+v", eventType, sub)
+		}
+	}
+
+	// An older renewal arriving last doesn't shorten the period
+	newer := subscriptionNow.Add(subscriptionDay * 60)
+	older := subscriptionNow.Add(subscriptionDay * 30)
+	sub := applySubscription(t, nil, subscription.Event{Type: subscription.Upgraded, Plan: entitlements.PlanRed}, subscriptionNow)
+	sub = applySubscription(t, sub, subscription.Event{Type: subscription.Renewed, CurrentPeriodEnd: &newer}, subscriptionNow)
+	sub = applySubscription(t, sub, subscription.Event{Type: subscription.Renewed, CurrentPeriodEnd: &older}, subscriptionNow)
+	if !sub.CurrentPeriodEnd.Equal(newer) {
+		t.Errorf("Expected the period to still end at %v, got %v", newer, sub.CurrentPeriodEnd)
+	}
+
+	// Same for a redelivered upgrade
+	sub = applySubscription(t, sub, subscription.Event{Type: subscription.Upgraded, Plan: entitlements.PlanRed}, subscriptionNow)
+	if !sub.CurrentPeriodEnd.Equal(newer) {
+		t.Errorf("Expected a late upgrade to keep the period, got %v", sub.CurrentPeriodEnd)
+	}
+}
+
+func TestSubscriptionLifetime(t *testing.T) {
+	lifetime := &subscription.Subscription{
+		Plan:             entitlements.PlanRed,
+		Status:           subscription.StatusActive,
+		CurrentPeriodEnd: subscription.Lifetime,
+	}
+
+	later := subscriptionNow.Add(subscriptionDay * 30)
+	for _, event := range []subscription.Event{
+		{Type: subscription.Upgraded, Plan: entitlements.PlanRed, CurrentPeriodEnd: &later},
+		{Type: subscription.Renewed, CurrentPeriodEnd: &later},
+	} {
+		sub := applySubscription(t, lifetime, event, subscriptionNow)
+		if !sub.CurrentPeriodEnd.Equal(subscription.Lifetime) {
+			t.Errorf("Expected %s to keep the lifetime period, got %v", event.Type, sub.CurrentPeriodEnd)
+		}
+	}
+}
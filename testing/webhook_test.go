@@ -0,0 +1,88 @@
+package testing
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/webhook"
+)
+
+func TestWebhookSignatureRoundTrip(t *testing.T) {
+	secrets := [][]byte{[]byte("old-secret"), []byte("new-secret")}
+	body := []byte(`{"event":"user.upgraded","data":{"user_id":"fb68025f-be8f-4649-aa15-0c2b6b1c6409"}}`)
+	now := time.Now()
+
+	header := webhook.Header(secrets[1:], now, body)
+
+	err := webhook.Verify(header, body, secrets, time.Minute*5, now)
+	if err != nil {
+		t.Fatalf("Expected a valid signature, got %v", err)
+	}
+
+	// Rotated out secrets no longer verify
+	err = webhook.Verify(header, body, secrets[:1], time.Minute*5, now)
+	if !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature with the old secret only, got %v", err)
+	}
+}
+
+func TestWebhookSignatureRejectsTampering(t *testing.T) {
+	secrets := [][]byte{[]byte("secret")}
+	body := []byte(`{"event":"user.upgraded"}`)
+	now := time.Now()
+
+	header := webhook.Header(secrets, now, body)
+
+	err := webhook.Verify(header, []byte(`{"event":"user.downgraded"}`), secrets, time.Minute*5, now)
+	if !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a modified body, got %v", err)
+	}
+
+	// Moving the timestamp invalidates the signature too
+	unix := strconv.FormatInt(now.Unix(), 10)
+	moved := strings.Replace(header, "t="+unix, "t="+strconv.FormatInt(now.Unix()+1, 10), 1)
+	err = webhook.Verify(moved, body, secrets, time.Minute*5, now)
+	if !errors.Is(err, webhook.ErrInvalidSignature) {
+		t.Errorf("Expected ErrInvalidSignature for a modified timestamp, got %v", err)
+	}
+
+	err = webhook.Verify("", body, secrets, time.Minute*5, now)
+	if !errors.Is(err, webhook.ErrMissingSignature) {
+		t.Errorf("Expected ErrMissingSignature, got %v", err)
+	}
+}
+
+func TestWebhookSignatureReplayWindow(t *testing.T) {
+	secrets := [][]byte{[]byte("secret")}
+	body := []byte(`{}`)
+	now := time.Now()
+
+	for _, signedAt := range []time.Time{now.Add(-time.Minute * 6), now.Add(time.Minute * 6)} {
+		header := webhook.Header(secrets, signedAt, body)
+
+		err := webhook.Verify(header, body, secrets, time.Minute*5, now)
+		if !errors.Is(err, webhook.ErrExpired) {
+			t.Errorf("Expected ErrExpired for a delivery signed at %v, got %v", signedAt, err)
+		}
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	base := time.Second * 30
+	maxDelay := time.Hour
+
+	expected := []time.Duration{base, base * 2, base * 4, base * 8}
+	for i, delay := range expected {
+		if got := webhook.Backoff(i+1, base, maxDelay); got != delay {
+			t.Errorf("Attempt %d: result %v expected to be equal to %v", i+1, got, delay)
+		}
+	}
+
+	// Large attempt counts must not overflow past the cap
+	if got := webhook.Backoff(100, base, maxDelay); got != maxDelay {
+		t.Errorf("Result: %v expected to be capped at %v", got, maxDelay)
+	}
+}
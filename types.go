@@ -1,10 +1,18 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
 	"sync/atomic"
 	"time"
 
+	"github.com/Alb3G/chirpy/internal/blobstore"
 	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/events"
+	"github.com/Alb3G/chirpy/internal/moderation"
+	"github.com/Alb3G/chirpy/internal/pubsub"
+	"github.com/didip/tollbooth/v7/limiter"
 	"github.com/google/uuid"
 )
 
@@ -13,30 +21,359 @@ type User struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Email        string    `json:"email"`
+	Handle       string    `json:"handle"`
+	DisplayName  string    `json:"display_name"`
 	Token        string    `json:"token"`
 	RefreshToken string    `json:"refresh_token"`
 	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Badge        string    `json:"badge,omitempty"`
+	AcceptsDms   bool      `json:"accepts_dms"`
+	// Only shown to the user themselves, when they ever subscribed
+	Subscription *Subscription `json:"subscription,omitempty"`
+}
+
+type Subscription struct {
+	Plan              string    `json:"plan"`
+	Status            string    `json:"status"`
+	CurrentPeriodEnd  time.Time `json:"current_period_end"`
+	CancelAtPeriodEnd bool      `json:"cancel_at_period_end"`
 }
 
 type UserRequestData struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	// Left out, the setting is unchanged.
+	AcceptsDms *bool `json:"accepts_dms"`
+}
+
+// PublicUser is what other users get to see about an account.
+type PublicUser struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Badge       string    `json:"badge,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserId    uuid.UUID `json:"user_id"`
+	ID         uuid.UUID         `json:"id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Body       string            `json:"body"`
+	UserId     uuid.UUID         `json:"user_id"`
+	Status     string            `json:"moderation_status"`
+	Visibility string            `json:"visibility"`
+	Entities   ChirpEntities     `json:"entities"`
+	Media      []MediaAttachment `json:"media"`
+	Preview    *LinkPreview      `json:"preview"`
+	Poll       *Poll             `json:"poll"`
+	EditedAt   *time.Time        `json:"edited_at"`
+	// Only set in the author's profile listing.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+type Poll struct {
+	ClosesAt    time.Time    `json:"closes_at"`
+	Closed      bool         `json:"closed"`
+	HideResults bool         `json:"hide_results"`
+	Options     []PollOption `json:"options"`
+	// Vote counts are null while results are hidden from the viewer.
+	TotalVotes  *int `json:"total_votes"`
+	VotedOption *int `json:"voted_option"`
+}
+
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes *int   `json:"votes"`
+}
+
+type PollRequest struct {
+	Options         []string `json:"options"`
+	DurationMinutes int      `json:"duration_minutes"`
+	HideResults     bool     `json:"hide_results"`
+}
+
+type VoteRequest struct {
+	Option int `json:"option"`
+}
+
+type Draft struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Body       string       `json:"body"`
+	Visibility string       `json:"visibility"`
+	MediaIds   []uuid.UUID  `json:"media_ids"`
+	Poll       *PollRequest `json:"poll"`
+	// Drafts have no publish_at, scheduled chirps do.
+	PublishAt *time.Time `json:"publish_at"`
+	// Why the scheduler couldn't publish it, if it tried.
+	LastError string `json:"last_error,omitempty"`
+}
+
+type DraftRequest struct {
+	Body       string       `json:"body"`
+	Visibility string       `json:"visibility"`
+	MediaIds   []uuid.UUID  `json:"media_ids"`
+	Poll       *PollRequest `json:"poll"`
+	PublishAt  *time.Time   `json:"publish_at"`
+}
+
+type Conversation struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	IsGroup     bool                 `json:"is_group"`
+	Members     []ConversationMember `json:"members"`
+	UnreadCount int                  `json:"unread_count"`
+}
+
+type ConversationMember struct {
+	UserID      uuid.UUID  `json:"user_id"`
+	Handle      string     `json:"handle"`
+	DisplayName string     `json:"display_name"`
+	LastReadAt  *time.Time `json:"last_read_at"`
+}
+
+type ConversationPage struct {
+	Conversations []Conversation `json:"conversations"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
+}
+
+type ConversationRequest struct {
+	MemberIds []uuid.UUID `json:"member_ids"`
+}
+
+type Message struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	ConversationID uuid.UUID   `json:"conversation_id"`
+	SenderID       uuid.UUID   `json:"sender_id"`
+	Body           string      `json:"body"`
+	ReadBy         []uuid.UUID `json:"read_by"`
+}
+
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+type MessageRequest struct {
+	Body string `json:"body"`
+}
+
+type List struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	OwnerID     uuid.UUID `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+}
+
+type ListRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	IsPrivate   bool   `json:"is_private"`
+}
+
+type WebhookEvent struct {
+	ID              uuid.UUID  `json:"id"`
+	ReceivedAt      time.Time  `json:"received_at"`
+	Provider        string     `json:"provider"`
+	ProviderEventID string     `json:"provider_event_id"`
+	EventType       string     `json:"event_type"`
+	Payload         string     `json:"payload"`
+	Status          string     `json:"status"`
+	Error           string     `json:"error,omitempty"`
+	Attempts        int        `json:"attempts"`
+	ProcessedAt     *time.Time `json:"processed_at"`
+}
+
+type WebhookEventPage struct {
+	Events     []WebhookEvent `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	// Only returned when the endpoint is created
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookEndpointRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+type WebhookDelivery struct {
+	ID        uuid.UUID       `json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	EventID   uuid.UUID       `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	// Only set while the delivery is pending.
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastStatusCode *int       `json:"last_status_code"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+type WebhookDeliveryPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// WebhookPayload is the body of every outbound webhook delivery. ID is
+// shared by the deliveries of one event to several endpoints and stays the
+// same across retries, so receivers can drop duplicates.
+type WebhookPayload struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// ChirpEvent is the payload of chirp.created, chirp.updated and chirp.deleted
+// events.
+type ChirpEvent struct {
+	ID               uuid.UUID  `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Body             string     `json:"body"`
+	UserID           uuid.UUID  `json:"user_id"`
+	ModerationStatus string     `json:"moderation_status"`
+	Visibility       string     `json:"visibility"`
+	PreviewURL       string     `json:"preview_url,omitempty"`
+	EditedAt         *time.Time `json:"edited_at,omitempty"`
+}
+
+// MessageEvent is the payload of message.sent events, with the members of
+// the conversation it was sent to.
+type MessageEvent struct {
+	Message   Message     `json:"message"`
+	MemberIds []uuid.UUID `json:"member_ids"`
+}
+
+// WSEnvelope wraps every WebSocket message in both directions. ID is set by
+// clients on their requests and echoed back in the replies.
+type WSEnvelope struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Channel string          `json:"channel,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url"`
+	SiteName    string `json:"site_name"`
+}
+
+type MediaAttachment struct {
+	ID           uuid.UUID `json:"id"`
+	MimeType     string    `json:"mime_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+}
+
+type ChirpEntities struct {
+	Mentions []MentionEntity `json:"mentions"`
+}
+
+// Start and End are byte offsets into the chirp body, covering the '@'.
+type MentionEntity struct {
+	UserID uuid.UUID `json:"user_id"`
+	Handle string    `json:"handle"`
+	Start  int       `json:"start"`
+	End    int       `json:"end"`
 }
 
 type apiConfig struct {
 	fileserverhits atomic.Int32
+	DB             *sql.DB
 	Queries        *database.Queries
 	Env            string
 	TokenSecret    string
-	Key            string
+	PolkaSecrets   [][]byte
+	Moderation     *moderation.Pipeline
+	BannedWords    *moderation.WordList
+	Blobs          blobstore.BlobStore
+	PreviewClient  *http.Client
+	Events         events.Bus
+	Stream         *pubsub.Hub
+	ChirpLimiters  map[string]*limiter.Limiter
+	WebhookClient  *http.Client
+	conns          *connTracker
+	trends         trendsCache
+}
+
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+type Trend struct {
+	Tag   string  `json:"tag"`
+	Uses  int32   `json:"uses"`
+	Score float64 `json:"score"`
+}
+
+type NotificationGroup struct {
+	IDs       []uuid.UUID `json:"ids"`
+	Type      string      `json:"type"`
+	ChirpID   *uuid.UUID  `json:"chirp_id,omitempty"`
+	ActorIDs  []uuid.UUID `json:"actor_ids"`
+	Count     int         `json:"count"`
+	Read      bool        `json:"read"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type NotificationPage struct {
+	Notifications []NotificationGroup `json:"notifications"`
+	NextCursor    string              `json:"next_cursor,omitempty"`
+}
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReporterID *uuid.UUID `json:"reporter_id"`
+	TargetType string     `json:"target_type"`
+	TargetID   uuid.UUID  `json:"target_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by,omitempty"`
+}
+
+type ReportPage struct {
+	Reports    []Report `json:"reports"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+type ModerationAction struct {
+	ID          uuid.UUID     `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ReportID    uuid.NullUUID `json:"report_id"`
+	ModeratorID uuid.UUID     `json:"moderator_id"`
+	Action      string        `json:"action"`
+	TargetType  string        `json:"target_type"`
+	TargetID    uuid.UUID     `json:"target_id"`
+	Reason      string        `json:"reason"`
 }
 
 type ErrorResponse struct {
@@ -48,12 +385,26 @@ type SuccessResponse struct {
 }
 
 type ChirpBody struct {
+	Body       string       `json:"body"`
+	Visibility string       `json:"visibility"`
+	MediaIds   []uuid.UUID  `json:"media_ids"`
+	Poll       *PollRequest `json:"poll"`
+}
+
+// EditChirpRequest replaces the body of a chirp, its media, visibility and
+// poll can't change.
+type EditChirpRequest struct {
 	Body string `json:"body"`
 }
 
 type UpgradeRequest struct {
+	// Polka's event ID, redeliveries of an event share it
+	ID    string `json:"id"`
 	Event string `json:"event"`
 	Data  struct {
 		UserId uuid.UUID `json:"user_id"`
+		Plan   string    `json:"plan"`
+		// End of the period paid for, on upgrades and renewals
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
 	}
 }
@@ -1,16 +1,34 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Alb3G/chirpy/internal/auth"
 	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/entitlements"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const DAY = time.Hour * 24
+const (
+	DAY               = time.Hour * 24
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+
+	MAX_DISPLAY_NAME_LENGTH = 50
+
+	// Postgres' name for the UNIQUE constraint of users.handle
+	USERS_HANDLE_CONSTRAINT = "users_handle_key"
+	PG_UNIQUE_VIOLATION     = "23505"
+)
 
 func respondWithError(w http.ResponseWriter, statusCode int, errMsg string) {
 	if statusCode > 499 {
@@ -35,43 +53,236 @@ func respondWithJSON(w http.ResponseWriter, statusCode int, payload interface{})
 	w.Write(data)
 }
 
-func validateChirpBody(body string, bannedWords []string) string {
-	words := strings.Fields(body)
+// authenticate returns the ID of the user behind the request's bearer JWT.
+func (ac *apiConfig) authenticate(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return ac.validateAccessToken(r.Context(), token)
+}
+
+// validateAccessToken wraps auth.ValidateJWT and also rejects tokens of users
+// that have been deleted or suspended since the token was issued.
+func (ac *apiConfig) validateAccessToken(ctx context.Context, token string) (uuid.UUID, error) {
+	userId, err := auth.ValidateJWT(token, ac.TokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	user, err := ac.Queries.GetUserById(ctx, userId)
+	if err != nil {
+		return uuid.Nil, errors.New("user not found")
+	}
+
+	if isSuspended(user, time.Now().UTC()) {
+		return uuid.Nil, errAccountSuspended
+	}
+
+	return userId, nil
+}
+
+func toChirp(dbc database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:         dbc.ID,
+		CreatedAt:  dbc.CreatedAt,
+		UpdatedAt:  dbc.UpdatedAt,
+		Body:       dbc.Body,
+		UserId:     dbc.UserID,
+		Status:     dbc.ModerationStatus,
+		Visibility: dbc.Visibility,
+		Entities: ChirpEntities{
+			Mentions: make([]MentionEntity, 0),
+		},
+		Media: make([]MediaAttachment, 0),
+	}
+	if dbc.EditedAt.Valid {
+		chirp.EditedAt = &dbc.EditedAt.Time
+	}
+
+	return chirp
+}
+
+// renderChirps converts database chirps into their JSON form, loading the
+// entities stored alongside them with one query per kind of entity.
+func (ac *apiConfig) renderChirps(ctx context.Context, viewer uuid.NullUUID, dbChirps []database.Chirp) ([]Chirp, error) {
+	chirps := make([]Chirp, 0, len(dbChirps))
+	if len(dbChirps) == 0 {
+		return chirps, nil
+	}
+
+	chirpIds := make([]uuid.UUID, 0, len(dbChirps))
+	byId := make(map[uuid.UUID]int, len(dbChirps))
+	for i, dbChirp := range dbChirps {
+		chirps = append(chirps, toChirp(dbChirp))
+		chirpIds = append(chirpIds, dbChirp.ID)
+		byId[dbChirp.ID] = i
+	}
+
+	mentions, err := ac.Queries.GetMentionsByChirpIds(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mention := range mentions {
+		chirp := &chirps[byId[mention.ChirpID]]
+		chirp.Entities.Mentions = append(chirp.Entities.Mentions, MentionEntity{
+			UserID: mention.UserID,
+			Handle: mention.Handle.String,
+			Start:  int(mention.StartOffset),
+			End:    int(mention.EndOffset),
+		})
+	}
+
+	uploads, err := ac.Queries.GetMediaUploadsByChirpIds(ctx, chirpIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, upload := range uploads {
+		chirp := &chirps[byId[upload.ChirpID.UUID]]
+		chirp.Media = append(chirp.Media, toMediaAttachment(upload))
+	}
+
+	previewUrls := make([]string, 0)
+	for _, dbChirp := range dbChirps {
+		if dbChirp.PreviewUrl.Valid {
+			previewUrls = append(previewUrls, dbChirp.PreviewUrl.String)
+		}
+	}
+
+	if len(previewUrls) > 0 {
+		previews, err := ac.Queries.GetLinkPreviewsByUrls(ctx, previewUrls)
+		if err != nil {
+			return nil, err
+		}
 
-	for i, word := range words {
-		for _, bannedWord := range bannedWords {
-			if strings.EqualFold(word, bannedWord) {
-				words[i] = "****"
+		byUrl := make(map[string]database.LinkPreview, len(previews))
+		for _, preview := range previews {
+			byUrl[preview.Url] = preview
+		}
+
+		for i, dbChirp := range dbChirps {
+			if !dbChirp.PreviewUrl.Valid {
+				continue
+			}
+			if preview, ok := byUrl[dbChirp.PreviewUrl.String]; ok {
+				chirps[i].Preview = toLinkPreview(preview)
 			}
 		}
 	}
-	return strings.Join(words, " ")
+
+	err = ac.renderPolls(ctx, viewer, chirps)
+	if err != nil {
+		return nil, err
+	}
+
+	return chirps, nil
 }
 
-func toChirp(dbc database.Chirp) Chirp {
-	return Chirp{
-		ID:        dbc.ID,
-		CreatedAt: dbc.CreatedAt,
-		UpdatedAt: dbc.UpdatedAt,
-		Body:      dbc.Body,
-		UserId:    dbc.UserID,
+func (ac *apiConfig) renderChirp(ctx context.Context, viewer uuid.NullUUID, dbChirp database.Chirp) (Chirp, error) {
+	chirps, err := ac.renderChirps(ctx, viewer, []database.Chirp{dbChirp})
+	if err != nil {
+		return Chirp{}, err
 	}
+
+	return chirps[0], nil
 }
 
-func toUser(dbu database.User, token *string) (User, error) {
+// toUser converts a user for its own eyes. Chirpy Red and the badge come
+// from sub, which is nil for users who never subscribed.
+func toUser(dbu database.User, sub *database.Subscription, token *string) (User, error) {
 	tokenValue := ""
 
 	if token != nil {
 		tokenValue = *token
 	}
 
+	now := time.Now().UTC()
+
 	return User{
 		ID:           dbu.ID,
 		CreatedAt:    dbu.CreatedAt,
 		UpdatedAt:    dbu.UpdatedAt,
 		Email:        dbu.Email,
+		Handle:       dbu.Handle.String,
+		DisplayName:  dbu.DisplayName.String,
 		Token:        tokenValue,
 		RefreshToken: "",
-		IsChirpyRed:  dbu.IsChirpyRed.Bool,
+		IsChirpyRed:  isActiveSubscription(sub, now),
+		Badge:        entitlements.ForPlan(userPlan(sub, now)).Badge,
+		AcceptsDms:   dbu.AcceptsDms,
+		Subscription: toSubscription(sub),
 	}, nil
 }
+
+// Cursors point at the last item of a page ordered by (created_at, id) and are
+// opaque to clients.
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	createdAtPart, idPart, found := strings.Cut(string(raw), "|")
+	if !found {
+		return time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, errors.New("invalid cursor")
+	}
+
+	return createdAt, id, nil
+}
+
+func parseLimit(raw string) (int32, error) {
+	if raw == "" {
+		return DEFAULT_PAGE_SIZE, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > MAX_PAGE_SIZE {
+		return 0, errors.New("limit must be a number between 1 and 100")
+	}
+
+	return int32(limit), nil
+}
+
+// paginateChirps trims a page of chirps fetched with limit+1 rows, using the
+// extra row only to know whether there is a next page.
+func paginateChirps(dbChirps []database.Chirp, limit int32) ([]database.Chirp, string) {
+	if len(dbChirps) <= int(limit) {
+		return dbChirps, ""
+	}
+
+	dbChirps = dbChirps[:limit]
+	last := dbChirps[len(dbChirps)-1]
+
+	return dbChirps, encodeCursor(last.CreatedAt, last.ID)
+}
+
+// escapeLike escapes the LIKE wildcards in s so it can be used as a literal prefix.
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(s)
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// value of constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == PG_UNIQUE_VIOLATION && pqErr.Constraint == constraint
+}
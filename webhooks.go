@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/database"
+	"github.com/Alb3G/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	DELIVERY_PENDING   = "pending"
+	DELIVERY_SUCCEEDED = "succeeded"
+	// Gave up after DELIVERY_MAX_ATTEMPTS, only a manual retry sends it again
+	DELIVERY_DEAD = "dead"
+
+	MAX_WEBHOOK_ENDPOINTS = 10
+
+	DELIVERY_SIGNATURE_HEADER = "Chirpy-Signature"
+	DELIVERY_INTERVAL         = time.Second * 5
+	DELIVERY_BATCH            = 50
+	DELIVERY_TIMEOUT          = time.Second * 10
+	// Claimed deliveries aren't claimed again for this long, the ones of an
+	// instance that died mid-send are retried once it runs out
+	DELIVERY_LEASE        = time.Minute
+	DELIVERY_MAX_ATTEMPTS = 10
+	DELIVERY_RETRY_BASE   = time.Second * 30
+	DELIVERY_RETRY_MAX    = time.Hour
+	// How much of a failed response body ends up in the delivery log
+	DELIVERY_MAX_ERROR_BODY = 512
+)
+
+// webhookEventTypes are the events endpoints can subscribe to. There is no
+// follow graph yet, so there is no follow.created to offer.
+var webhookEventTypes = []string{EVENT_CHIRP_CREATED, EVENT_CHIRP_UPDATED, EVENT_CHIRP_DELETED}
+
+func toWebhookEndpoint(dbEndpoint database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:         dbEndpoint.ID,
+		CreatedAt:  dbEndpoint.CreatedAt,
+		UpdatedAt:  dbEndpoint.UpdatedAt,
+		URL:        dbEndpoint.Url,
+		EventTypes: dbEndpoint.EventTypes,
+	}
+}
+
+func toWebhookDelivery(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:        dbDelivery.ID,
+		CreatedAt: dbDelivery.CreatedAt,
+		EventID:   dbDelivery.EventID,
+		EventType: dbDelivery.EventType,
+		Payload:   json.RawMessage(dbDelivery.Payload),
+		Status:    dbDelivery.Status,
+		Attempts:  int(dbDelivery.Attempts),
+		LastError: dbDelivery.LastError.String,
+	}
+	if dbDelivery.Status == DELIVERY_PENDING {
+		delivery.NextAttemptAt = &dbDelivery.NextAttemptAt
+	}
+	if dbDelivery.LastStatusCode.Valid {
+		statusCode := int(dbDelivery.LastStatusCode.Int32)
+		delivery.LastStatusCode = &statusCode
+	}
+	if dbDelivery.DeliveredAt.Valid {
+		delivery.DeliveredAt = &dbDelivery.DeliveredAt.Time
+	}
+
+	return delivery
+}
+
+// newWebhookSecret returns a random secret deliveries to an endpoint are
+// signed with.
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
+
+// decodeWebhookEndpoint reads and validates an endpoint from the request body.
+// Plain http is only accepted in the dev environment.
+func (ac *apiConfig) decodeWebhookEndpoint(w http.ResponseWriter, r *http.Request) (WebhookEndpointRequest, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
+
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	var reqData WebhookEndpointRequest
+	err := decoder.Decode(&reqData)
+	if err != nil {
+		respondWithError(w, 400, "Invalid JSON format")
+		return WebhookEndpointRequest{}, false
+	}
+
+	endpointUrl, err := url.Parse(reqData.URL)
+	if err != nil || endpointUrl.Host == "" || (endpointUrl.Scheme != "https" && (endpointUrl.Scheme != "http" || ac.Env != "dev")) {
+		respondWithError(w, 400, "url must be an absolute https URL")
+		return WebhookEndpointRequest{}, false
+	}
+
+	if len(reqData.EventTypes) == 0 {
+		respondWithError(w, 400, "Subscribe to at least one event type")
+		return WebhookEndpointRequest{}, false
+	}
+	for _, eventType := range reqData.EventTypes {
+		if !slices.Contains(webhookEventTypes, eventType) {
+			respondWithError(w, 400, fmt.Sprintf("Unknown event type %q. Must be 'chirp.created', 'chirp.updated' or 'chirp.deleted'", eventType))
+			return WebhookEndpointRequest{}, false
+		}
+	}
+	slices.Sort(reqData.EventTypes)
+	reqData.EventTypes = slices.Compact(reqData.EventTypes)
+
+	return reqData, true
+}
+
+// webhookEndpointTarget authenticates the request and loads its
+// {endpointID}. Endpoints of other users answer 404, as if they didn't exist.
+func (ac *apiConfig) webhookEndpointTarget(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpointId, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid endpoint ID format")
+		return database.WebhookEndpoint{}, false
+	}
+
+	dbEndpoint, err := ac.Queries.GetWebhookEndpointById(r.Context(), endpointId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && dbEndpoint.UserID != userId) {
+		respondWithError(w, 404, "Webhook endpoint not found")
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return database.WebhookEndpoint{}, false
+	}
+
+	return dbEndpoint, true
+}
+
+// createWebhookEndpointHandler registers an endpoint for the caller's
+// events. Its signing secret is only ever returned here.
+func (ac *apiConfig) createWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	reqData, ok := ac.decodeWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := ac.Queries.CountWebhookEndpoints(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+	if count >= MAX_WEBHOOK_ENDPOINTS {
+		respondWithError(w, 409, fmt.Sprintf("You can have at most %d webhook endpoints", MAX_WEBHOOK_ENDPOINTS))
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	dbEndpoint, err := ac.Queries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID:     userId,
+		Url:        reqData.URL,
+		Secret:     secret,
+		EventTypes: reqData.EventTypes,
+	})
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	endpoint := toWebhookEndpoint(dbEndpoint)
+	endpoint.Secret = dbEndpoint.Secret
+
+	respondWithJSON(w, 201, endpoint)
+}
+
+func (ac *apiConfig) getWebhookEndpointsHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := ac.authenticate(r)
+	if err != nil {
+		respondWithError(w, 401, err.Error())
+		return
+	}
+
+	dbEndpoints, err := ac.Queries.GetWebhookEndpointsByUserId(r.Context(), userId)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	endpoints := make([]WebhookEndpoint, 0, len(dbEndpoints))
+	for _, dbEndpoint := range dbEndpoints {
+		endpoints = append(endpoints, toWebhookEndpoint(dbEndpoint))
+	}
+
+	respondWithJSON(w, 200, endpoints)
+}
+
+func (ac *apiConfig) getWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	dbEndpoint, ok := ac.webhookEndpointTarget(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, 200, toWebhookEndpoint(dbEndpoint))
+}
+
+// deleteWebhookEndpointHandler removes an endpoint along with its delivery
+// log. Deliveries still pending are dropped.
+func (ac *apiConfig) deleteWebhookEndpointHandler(w http.ResponseWriter, r *http.Request) {
+	dbEndpoint, ok := ac.webhookEndpointTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := ac.Queries.DeleteWebhookEndpoint(r.Context(), dbEndpoint.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// getWebhookDeliveriesHandler pages through the delivery log of an endpoint,
+// newest first, optionally only the deliveries in ?status.
+func (ac *apiConfig) getWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	dbEndpoint, ok := ac.webhookEndpointTarget(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.GetWebhookDeliveriesParams{
+		EndpointID: dbEndpoint.ID,
+	}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		if status != DELIVERY_PENDING && status != DELIVERY_SUCCEEDED && status != DELIVERY_DEAD {
+			respondWithError(w, 400, "Invalid status. Must be 'pending', 'succeeded' or 'dead'")
+			return
+		}
+		params.Status = sql.NullString{String: status, Valid: true}
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	params.MaxResults = limit + 1
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		createdAt, id, err := decodeCursor(cursor)
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: createdAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	dbDeliveries, err := ac.Queries.GetWebhookDeliveries(r.Context(), params)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	page := WebhookDeliveryPage{Deliveries: make([]WebhookDelivery, 0, len(dbDeliveries))}
+	if len(dbDeliveries) > int(limit) {
+		dbDeliveries = dbDeliveries[:limit]
+		last := dbDeliveries[len(dbDeliveries)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	for _, dbDelivery := range dbDeliveries {
+		page.Deliveries = append(page.Deliveries, toWebhookDelivery(dbDelivery))
+	}
+
+	respondWithJSON(w, 200, page)
+}
+
+// retryWebhookDeliveryHandler queues a delivery again with a fresh set of
+// attempts, mostly to get dead ones out once the endpoint is fixed.
+func (ac *apiConfig) retryWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	dbEndpoint, ok := ac.webhookEndpointTarget(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryId, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, 400, "Invalid delivery ID format")
+		return
+	}
+
+	dbDelivery, err := ac.Queries.GetWebhookDeliveryById(r.Context(), database.GetWebhookDeliveryByIdParams{
+		ID:         deliveryId,
+		EndpointID: dbEndpoint.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, 404, "Webhook delivery not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	if dbDelivery.Status == DELIVERY_PENDING {
+		respondWithError(w, 409, "Delivery is already pending")
+		return
+	}
+
+	dbDelivery, err = ac.Queries.RetryWebhookDelivery(r.Context(), dbDelivery.ID)
+	if err != nil {
+		respondWithError(w, 500, err.Error())
+		return
+	}
+
+	respondWithJSON(w, 200, toWebhookDelivery(dbDelivery))
+}
+
+// queueWebhookDeliveries stores a delivery of a chirp event for every
+// endpoint of the chirp's author subscribed to it. q should be bound to the
+// transaction making the change, so the deliveries are stored exactly when
+// the change is. From there the dispatcher keeps trying until the endpoint
+// accepts them or they are dead.
+func queueWebhookDeliveries(ctx context.Context, q *database.Queries, eventType string, chirp database.Chirp) error {
+	data, err := json.Marshal(toChirpEvent(chirp))
+	if err != nil {
+		return err
+	}
+
+	eventId := uuid.New()
+	payload, err := json.Marshal(WebhookPayload{
+		ID:        eventId,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateWebhookDeliveries(ctx, database.CreateWebhookDeliveriesParams{
+		EventID:   eventId,
+		EventType: eventType,
+		Payload:   string(payload),
+		UserID:    chirp.UserID,
+	})
+
+	return err
+}
+
+// runWebhookDispatcher sends due deliveries every DELIVERY_INTERVAL until
+// ctx is done. Deliveries are claimed with a lease, so several instances
+// can run it side by side without sending anything twice.
+func (ac *apiConfig) runWebhookDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(DELIVERY_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more may be due, don't wait a tick for those
+		if ac.dispatchWebhooks(ctx) == DELIVERY_BATCH {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchWebhooks sends a batch of due deliveries concurrently and returns
+// how many it claimed.
+func (ac *apiConfig) dispatchWebhooks(ctx context.Context) int {
+	deliveries, err := ac.Queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().UTC().Add(DELIVERY_LEASE),
+		MaxResults: DELIVERY_BATCH,
+	})
+	if err != nil {
+		log.Printf("Error claiming webhook deliveries: %v", err)
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ac.deliverWebhook(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+// deliverWebhook makes one attempt at a delivery and records how it went.
+// Anything but a 2xx answer counts as a failure and is retried with
+// exponential backoff, until DELIVERY_MAX_ATTEMPTS makes it dead.
+func (ac *apiConfig) deliverWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) {
+	statusCode, err := ac.sendWebhook(ctx, delivery)
+	if err == nil {
+		err = ac.Queries.SetWebhookDeliverySucceeded(ctx, database.SetWebhookDeliverySucceededParams{
+			LastStatusCode: statusCode,
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			log.Printf("Error recording webhook delivery %s: %v", delivery.ID, err)
+		}
+		return
+	}
+
+	attempts := int(delivery.Attempts) + 1
+	status := DELIVERY_PENDING
+	if attempts >= DELIVERY_MAX_ATTEMPTS {
+		status = DELIVERY_DEAD
+	}
+
+	err = ac.Queries.SetWebhookDeliveryFailed(ctx, database.SetWebhookDeliveryFailedParams{
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(webhook.Backoff(attempts, DELIVERY_RETRY_BASE, DELIVERY_RETRY_MAX)),
+		LastStatusCode: statusCode,
+		LastError:      sql.NullString{String: err.Error(), Valid: true},
+		ID:             delivery.ID,
+	})
+	if err != nil {
+		log.Printf("Error recording webhook delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// sendWebhook posts the payload of delivery to its endpoint, signed with the
+// endpoint's secret. The status code is set whenever the endpoint answered.
+func (ac *apiConfig) sendWebhook(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) (sql.NullInt32, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(body))
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set("Chirpy-Event", delivery.EventType)
+	req.Header.Set("Chirpy-Event-Id", delivery.EventID.String())
+	req.Header.Set("Chirpy-Delivery", delivery.ID.String())
+	req.Header.Set(DELIVERY_SIGNATURE_HEADER, webhook.Header([][]byte{[]byte(delivery.Secret)}, time.Now(), body))
+
+	res, err := ac.WebhookClient.Do(req)
+	if err != nil {
+		return sql.NullInt32{}, err
+	}
+	defer res.Body.Close()
+
+	statusCode := sql.NullInt32{Int32: int32(res.StatusCode), Valid: true}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return statusCode, nil
+	}
+
+	resBody, _ := io.ReadAll(io.LimitReader(res.Body, DELIVERY_MAX_ERROR_BODY))
+
+	// The body ends up in last_error, Postgres rejects text with NUL bytes or
+	// invalid UTF-8, and the failure would then never be recorded
+	cleanBody := strings.ToValidUTF8(strings.ReplaceAll(string(resBody), "\x00", ""), "")
+
+	return statusCode, fmt.Errorf("endpoint answered %d: %s", res.StatusCode, cleanBody)
+}
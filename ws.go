@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Alb3G/chirpy/internal/auth"
+	"github.com/Alb3G/chirpy/internal/pubsub"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	WS_CHANNEL_TIMELINE      = "timeline"
+	WS_CHANNEL_NOTIFICATIONS = "notifications"
+	WS_CHANNEL_DMS           = "dms"
+
+	// Sent by clients
+	WS_AUTH        = "auth"
+	WS_SUBSCRIBE   = "subscribe"
+	WS_UNSUBSCRIBE = "unsubscribe"
+
+	// Sent by the server
+	WS_READY        = "ready"
+	WS_SUBSCRIBED   = "subscribed"
+	WS_UNSUBSCRIBED = "unsubscribed"
+	WS_EVENT        = "event"
+	WS_REAUTH       = "reauth"
+	WS_ERROR        = "error"
+
+	WS_AUTH_TIMEOUT = time.Second * 10
+	// Clients are asked for a fresh token this long before theirs expires
+	WS_REAUTH_NOTICE = time.Minute
+	WS_PING_INTERVAL = time.Second * 30
+	WS_PONG_WAIT     = time.Second * 60
+	WS_WRITE_WAIT    = time.Second * 10
+	WS_CLOSE_GRACE   = time.Second * 2
+	WS_MAX_MESSAGE   = 1 << 16
+)
+
+var wsChannels = map[string]bool{
+	WS_CHANNEL_TIMELINE:      true,
+	WS_CHANNEL_NOTIFICATIONS: true,
+	WS_CHANNEL_DMS:           true,
+}
+
+var errWSUnauthenticated = errors.New("authentication required")
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsConn is one authenticated WebSocket. Only its serve loop writes to the
+// socket, its read loop hands client messages over through incoming.
+type wsConn struct {
+	ac        *apiConfig
+	conn      *websocket.Conn
+	userId    uuid.UUID
+	expiresAt time.Time
+	channels  map[string]bool
+	incoming  chan WSEnvelope
+	readErr   chan error
+}
+
+// wsHandler upgrades to a WebSocket multiplexing the caller's timeline,
+// notifications and DMs. Browsers can't set headers on WebSockets, so the
+// access token comes either as a bearer header or in a first "auth" message.
+// When it is about to expire the server sends "reauth" and the client has
+// until the expiry to send a new one, or the connection is closed.
+func (ac *apiConfig) wsHandler(w http.ResponseWriter, r *http.Request) {
+	if !ac.conns.track() {
+		respondWithError(w, 503, "Server is shutting down")
+		return
+	}
+	defer ac.conns.done()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered with an error
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(WS_MAX_MESSAGE)
+
+	wc := &wsConn{
+		ac:       ac,
+		conn:     conn,
+		channels: make(map[string]bool),
+		incoming: make(chan WSEnvelope),
+		readErr:  make(chan error, 1),
+	}
+
+	// Subscribing before authenticating means no event is lost in between
+	sub, _ := ac.Stream.Subscribe(0, false)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go wc.readLoop(ctx)
+
+	if token, err := auth.GetBearerToken(r.Header); err == nil {
+		err = wc.authenticate(ctx, token)
+		if err != nil {
+			wc.close(websocket.ClosePolicyViolation, err.Error())
+			return
+		}
+	} else if !wc.awaitAuth(ctx) {
+		return
+	}
+
+	wc.send(WS_READY, "", "", wc.session())
+
+	wc.serve(ctx, sub)
+}
+
+// readLoop forwards client messages to the serve loop until the connection
+// fails or closes.
+func (wc *wsConn) readLoop(ctx context.Context) {
+	wc.conn.SetReadDeadline(time.Now().Add(WS_PONG_WAIT))
+	wc.conn.SetPongHandler(func(string) error {
+		return wc.conn.SetReadDeadline(time.Now().Add(WS_PONG_WAIT))
+	})
+
+	for {
+		var msg WSEnvelope
+		err := wc.conn.ReadJSON(&msg)
+		if err != nil {
+			wc.readErr <- err
+			return
+		}
+
+		select {
+		case wc.incoming <- msg:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// awaitAuth waits for the first message, which has to be a valid "auth".
+func (wc *wsConn) awaitAuth(ctx context.Context) bool {
+	timeout := time.NewTimer(WS_AUTH_TIMEOUT)
+	defer timeout.Stop()
+
+	select {
+	case msg := <-wc.incoming:
+		if msg.Type != WS_AUTH {
+			wc.close(websocket.ClosePolicyViolation, errWSUnauthenticated.Error())
+			return false
+		}
+		err := wc.authenticate(ctx, authToken(msg))
+		if err != nil {
+			wc.close(websocket.ClosePolicyViolation, err.Error())
+			return false
+		}
+		return true
+	case <-timeout.C:
+		wc.close(websocket.ClosePolicyViolation, errWSUnauthenticated.Error())
+		return false
+	case <-wc.readErr:
+		return false
+	case <-wc.ac.conns.Draining():
+		wc.close(websocket.CloseGoingAway, "server shutting down")
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func authToken(msg WSEnvelope) string {
+	var data struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(msg.Data, &data)
+
+	return data.Token
+}
+
+// authenticate validates token for the connection. A connection stays bound
+// to the user it was opened by, re-authenticating as someone else fails.
+func (wc *wsConn) authenticate(ctx context.Context, token string) error {
+	userId, err := wc.ac.validateAccessToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	_, expiresAt, err := auth.ParseJWT(token, wc.ac.TokenSecret)
+	if err != nil {
+		return err
+	}
+
+	if wc.userId != uuid.Nil && wc.userId != userId {
+		return errors.New("token belongs to another user")
+	}
+
+	wc.userId = userId
+	wc.expiresAt = expiresAt
+
+	return nil
+}
+
+func (wc *wsConn) session() any {
+	return struct {
+		UserID    uuid.UUID `json:"user_id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{
+		UserID:    wc.userId,
+		ExpiresAt: wc.expiresAt,
+	}
+}
+
+func (wc *wsConn) serve(ctx context.Context, sub *pubsub.Subscription) {
+	ping := time.NewTicker(WS_PING_INTERVAL)
+	defer ping.Stop()
+
+	reauth := time.NewTimer(time.Until(wc.expiresAt.Add(-WS_REAUTH_NOTICE)))
+	defer reauth.Stop()
+	expiry := time.NewTimer(time.Until(wc.expiresAt))
+	defer expiry.Stop()
+
+	viewer := newStreamViewer(uuid.NullUUID{UUID: wc.userId, Valid: true})
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wc.readErr:
+			return
+		case <-wc.ac.conns.Draining():
+			wc.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-ping.C:
+			wc.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_WAIT))
+			if err := wc.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-reauth.C:
+			wc.send(WS_REAUTH, "", "", wc.session())
+		case <-expiry.C:
+			wc.close(websocket.ClosePolicyViolation, "token expired")
+			return
+		case msg := <-wc.incoming:
+			wc.handle(ctx, msg)
+
+			// A successful reauth moves the deadlines
+			if msg.Type == WS_AUTH {
+				reauth.Reset(time.Until(wc.expiresAt.Add(-WS_REAUTH_NOTICE)))
+				expiry.Reset(time.Until(wc.expiresAt))
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Too far behind, the client has to reload what it shows
+				wc.close(websocket.CloseTryAgainLater, sub.Err().Error())
+				return
+			}
+
+			channel, data, ok := wc.ac.wsEventData(ctx, viewer, wc.channels, event)
+			if ok {
+				wc.send(WS_EVENT, "", channel, struct {
+					Event string `json:"event"`
+					Data  any    `json:"data"`
+				}{
+					Event: event.Type,
+					Data:  data,
+				})
+			}
+		}
+	}
+}
+
+func (wc *wsConn) handle(ctx context.Context, msg WSEnvelope) {
+	switch msg.Type {
+	case WS_AUTH:
+		err := wc.authenticate(ctx, authToken(msg))
+		if err != nil {
+			wc.sendError(msg, err.Error())
+			return
+		}
+		wc.send(WS_READY, msg.ID, "", wc.session())
+	case WS_SUBSCRIBE, WS_UNSUBSCRIBE:
+		if !wsChannels[msg.Channel] {
+			wc.sendError(msg, "Unknown channel")
+			return
+		}
+
+		if msg.Type == WS_SUBSCRIBE {
+			wc.channels[msg.Channel] = true
+			wc.send(WS_SUBSCRIBED, msg.ID, msg.Channel, nil)
+		} else {
+			delete(wc.channels, msg.Channel)
+			wc.send(WS_UNSUBSCRIBED, msg.ID, msg.Channel, nil)
+		}
+	default:
+		wc.sendError(msg, "Unknown message type")
+	}
+}
+
+// wsEventData picks the channel a hub event goes to for viewer, if it is
+// subscribed to it, and what to send. There is no follow graph, so the
+// timeline has every chirp viewer can see. Mentions are the only
+// notifications chirps create.
+func (ac *apiConfig) wsEventData(ctx context.Context, viewer *streamViewer, channels map[string]bool, event pubsub.Event) (string, any, bool) {
+	switch payload := event.Payload.(type) {
+	case streamChirp:
+		if channels[WS_CHANNEL_NOTIFICATIONS] && event.Type == EVENT_CHIRP_CREATED && payload.Chirp.UserID != viewer.ID.UUID {
+			notification, ok := ac.mentionNotification(ctx, viewer, payload)
+			if ok {
+				return WS_CHANNEL_NOTIFICATIONS, notification, true
+			}
+		}
+
+		if !channels[WS_CHANNEL_TIMELINE] {
+			return "", nil, false
+		}
+
+		data, ok := ac.chirpEventData(ctx, viewer, uuid.NullUUID{}, event)
+		return WS_CHANNEL_TIMELINE, data, ok
+	case MessageEvent:
+		if !channels[WS_CHANNEL_DMS] {
+			return "", nil, false
+		}
+
+		for _, memberId := range payload.MemberIds {
+			if memberId == viewer.ID.UUID {
+				return WS_CHANNEL_DMS, payload.Message, true
+			}
+		}
+	}
+
+	return "", nil, false
+}
+
+// mentionNotification builds the notification frame for a new chirp that
+// mentions viewer, along with their new unread count.
+func (ac *apiConfig) mentionNotification(ctx context.Context, viewer *streamViewer, sc streamChirp) (any, bool) {
+	if !sc.Mentioned[viewer.ID.UUID] {
+		return nil, false
+	}
+
+	visible, err := ac.canSee(ctx, viewer, sc)
+	if err != nil || !visible {
+		return nil, false
+	}
+
+	count, err := ac.Queries.CountUnreadNotifications(ctx, viewer.ID.UUID)
+	if err != nil {
+		log.Printf("Error counting notifications of %s: %v", viewer.ID.UUID, err)
+		return nil, false
+	}
+
+	return struct {
+		Type        string    `json:"type"`
+		ActorID     uuid.UUID `json:"actor_id"`
+		ChirpID     uuid.UUID `json:"chirp_id"`
+		UnreadCount int64     `json:"unread_count"`
+	}{
+		Type:        NOTIFICATION_MENTION,
+		ActorID:     sc.Chirp.UserID,
+		ChirpID:     sc.Chirp.ID,
+		UnreadCount: count,
+	}, true
+}
+
+func (wc *wsConn) send(msgType, id, channel string, data any) {
+	msg := WSEnvelope{
+		Type:    msgType,
+		ID:      id,
+		Channel: channel,
+	}
+
+	if data != nil {
+		dat, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Error parsing json object: %v", err)
+			return
+		}
+		msg.Data = dat
+	}
+
+	wc.conn.SetWriteDeadline(time.Now().Add(WS_WRITE_WAIT))
+	// Failed writes surface as read errors, which end the connection
+	wc.conn.WriteJSON(msg)
+}
+
+func (wc *wsConn) sendError(req WSEnvelope, message string) {
+	wc.send(WS_ERROR, req.ID, req.Channel, struct {
+		Error string `json:"error"`
+	}{
+		Error: message,
+	})
+}
+
+// close starts the closing handshake and gives the client a moment to answer
+// before the connection is dropped.
+func (wc *wsConn) close(code int, reason string) {
+	deadline := time.Now().Add(WS_WRITE_WAIT)
+	err := wc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	if err != nil {
+		return
+	}
+
+	select {
+	case <-wc.readErr:
+	case <-time.After(WS_CLOSE_GRACE):
+	}
+}